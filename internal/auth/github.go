@@ -13,6 +13,8 @@ import (
 func GithubAdapter(v *viper.Viper, r *gin.RouterGroup, routerGroupPath string) *gin.RouterGroup {
 	scopes := []string{
 		"repo",
+		// needed to read the teams of the user, used by the quotas
+		"read:org",
 	}
 	fmt.Println("enabling github authentication")
 	githubSecret := config.CheckAndGetString(v, "GITHUB_OAUTH_SECRET")
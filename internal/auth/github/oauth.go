@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -26,11 +27,22 @@ type Credentials struct {
 	ClientSecret string `json:"secret"`
 }
 
+// AuthenticatedUserKey is the key of the AuthUser in the gin context and in the session
+const AuthenticatedUserKey = "authenticated_user"
+
+// the teams of the user are cached in the session, so they are not listed on every request
+const (
+	teamsKey          = "teams"
+	teamsCheckedAtKey = "teams_checked_at"
+	teamsTTL          = 10 * time.Minute
+)
+
 // AuthUser rapresents datas of authenticated user
 type AuthUser struct {
-	Login              string `json:"login"`
-	Name               string `json:"name"`
-	OrganizationNeeded bool   `json:"organization_needed"`
+	Login              string   `json:"login"`
+	Name               string   `json:"name"`
+	OrganizationNeeded bool     `json:"organization_needed"`
+	Teams              []string `json:"teams"`
 }
 
 var (
@@ -145,13 +157,15 @@ func CheckAuthenticatedUser() gin.HandlerFunc {
 		}
 		if isMember {
 			fmt.Printf("the user %v is part of the membership required", user.GetLogin())
+			teams := cachedTeams(ctx, thisSession, client)
 			setUser := AuthUser{
 				Login:              user.GetLogin(),
 				Name:               user.GetName(),
 				OrganizationNeeded: isMember,
+				Teams:              teams,
 			}
-			ctx.Set("authenticated_user", setUser)
-			thisSession.Set("authenticated_user", setUser)
+			ctx.Set(AuthenticatedUserKey, setUser)
+			thisSession.Set(AuthenticatedUserKey, setUser)
 			thisSession.Save()
 			ctx.Next()
 
@@ -162,6 +176,45 @@ func CheckAuthenticatedUser() gin.HandlerFunc {
 	}
 }
 
+// cachedTeams returns the teams of the user cached in the session, listing them again once expired.
+// A failed lookup, e.g. for the sessions issued without the read:org scope, gives no teams
+func cachedTeams(ctx *gin.Context, thisSession sessions.Session, client *github.Client) []string {
+	checkedAt, ok := thisSession.Get(teamsCheckedAtKey).(int64)
+	if ok && time.Since(time.Unix(checkedAt, 0)) < teamsTTL {
+		teams, _ := thisSession.Get(teamsKey).([]string)
+		return teams
+	}
+	teams, err := userTeams(ctx, client)
+	if err != nil {
+		log.Printf("error getting user teams, going on without them: %v", err)
+		teams = []string{}
+	}
+	thisSession.Set(teamsKey, teams)
+	thisSession.Set(teamsCheckedAtKey, time.Now().Unix())
+	return teams
+}
+
+// userTeams returns the slugs of the teams of the required organization the user belongs to
+func userTeams(ctx *gin.Context, client *github.Client) ([]string, error) {
+	teams := []string{}
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Teams.ListUserTeams(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, team := range page {
+			if team.GetOrganization().GetLogin() == organizationRequired {
+				teams = append(teams, team.GetSlug())
+			}
+		}
+		if resp.NextPage == 0 {
+			return teams, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
 func randToken() string {
 	b := make([]byte, 32)
 	rand.Read(b)
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lzecca78/one/internal/auth/github"
+)
+
+// CurrentUser returns the login of the user authenticated by the adapter, empty without authentication
+func CurrentUser(c *gin.Context) string {
+	value, ok := c.Get(github.AuthenticatedUserKey)
+	if !ok {
+		return ""
+	}
+	user, ok := value.(github.AuthUser)
+	if !ok {
+		return ""
+	}
+	return user.Login
+}
+
+// CurrentTeams returns the teams of the user authenticated by the adapter, empty without authentication
+func CurrentTeams(c *gin.Context) []string {
+	value, ok := c.Get(github.AuthenticatedUserKey)
+	if !ok {
+		return nil
+	}
+	user, ok := value.(github.AuthUser)
+	if !ok {
+		return nil
+	}
+	return user.Teams
+}
@@ -0,0 +1,147 @@
+package ci
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lzecca78/one/internal/jenkins"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// consolePollInterval is the interval between two fetches of the console of a running build
+const consolePollInterval = 2 * time.Second
+
+// JenkinsProvider runs the pipelines as jenkins jobs, the pipeline set of a universe is a folder
+type JenkinsProvider struct {
+	client *jenkins.JenkinsClient
+}
+
+// NewJenkinsProvider initialize the jenkins client
+func NewJenkinsProvider(v *viper.Viper) *JenkinsProvider {
+	return &JenkinsProvider{client: jenkins.NewJenkinsClient(v)}
+}
+
+// Config returns the configuration of the jenkins client
+func (p *JenkinsProvider) Config() interface{} {
+	return p.client.Config
+}
+
+// Repos returns the repositories with a template job
+func (p *JenkinsProvider) Repos() []string {
+	return p.client.GetRepos()
+}
+
+// CreatePipelines creates the folder of the universe with a job for each project
+func (p *JenkinsProvider) CreatePipelines(jobsParams *pipeline.JobsParameters, namespace string) (map[string]string, map[string]*pipeline.Trigger, error) {
+	return p.client.ConfigureJobs(jobsParams, namespace)
+}
+
+// TriggerBuild builds the job of the repo with the parameters configured for it
+func (p *JenkinsProvider) TriggerBuild(repo, job, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
+	return p.client.BuildJob(repo, job, namespace, build)
+}
+
+// Status returns the result of the last build of the jobs of the universe
+func (p *JenkinsProvider) Status(namespace string) (pipeline.JobsStatuses, error) {
+	return p.client.GetJobStatus(namespace)
+}
+
+// Builds returns the status and the last builds of the job
+func (p *JenkinsProvider) Builds(repo, job, namespace string, limit int) (*pipeline.PipelineBuilds, error) {
+	return p.client.JobBuilds(repo, namespace, job, limit)
+}
+
+// ResolveTrigger looks up the queue item of the trigger
+func (p *JenkinsProvider) ResolveTrigger(trigger *pipeline.Trigger) error {
+	return p.client.ResolveTrigger(trigger)
+}
+
+// Build returns the build of the job with the given number
+func (p *JenkinsProvider) Build(repo, job, namespace string, number int) (*pipeline.Build, error) {
+	return p.client.JobBuild(repo, namespace, job, number)
+}
+
+// Replay builds again the job of the repo
+func (p *JenkinsProvider) Replay(repo, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
+	return p.client.ReplayJob(repo, repo, namespace, build)
+}
+
+// UpdatePipeline rewrites the branch of the job of the repo
+func (p *JenkinsProvider) UpdatePipeline(repo, job, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
+	return p.client.UpdateJob(repo, job, namespace, build)
+}
+
+// RenderPipeline renders the job of the repo with or without the scm triggers
+func (p *JenkinsProvider) RenderPipeline(repo, job, namespace string, jobsParams *pipeline.JobsParameters) error {
+	return p.client.RenderJob(repo, job, namespace, jobsParams)
+}
+
+// Teardown deletes the folder of the universe
+func (p *JenkinsProvider) Teardown(namespace string) error {
+	return p.client.DeleteFolder(namespace)
+}
+
+// PipelineSets returns the folders in the root of jenkins
+func (p *JenkinsProvider) PipelineSets() ([]string, error) {
+	return p.client.ListFolders()
+}
+
+// StreamLog follows the console of the build through the progressiveText api.
+// The last build is resolved to its number once, so the offset is not applied to a build started meanwhile
+func (p *JenkinsProvider) StreamLog(ctx context.Context, job, namespace, build string) (<-chan string, <-chan error) {
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		if build == "" {
+			// only the number of the build is needed, not the sha of the repo
+			builds, err := p.client.JobBuilds("", namespace, job, 1)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if len(builds.Builds) == 0 {
+				errs <- errors.Errorf("pipeline %s has no builds", job)
+				return
+			}
+			build = strconv.Itoa(builds.Builds[0].Number)
+		}
+		var start int64
+		// a line split between two fetches is sent once complete
+		var partial string
+		for {
+			text, next, more, err := p.client.ProgressiveText(namespace, job, build, start)
+			if err != nil {
+				errs <- err
+				return
+			}
+			start = next
+			chunk := strings.Split(partial+text, "\n")
+			partial = chunk[len(chunk)-1]
+			if !more && partial != "" {
+				chunk = append(chunk, "")
+				partial = ""
+			}
+			for _, line := range chunk[:len(chunk)-1] {
+				select {
+				case lines <- strings.TrimSuffix(line, "\r"):
+				case <-ctx.Done():
+					return
+				}
+			}
+			if !more {
+				return
+			}
+			select {
+			case <-time.After(consolePollInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return lines, errs
+}
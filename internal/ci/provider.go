@@ -0,0 +1,65 @@
+package ci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lzecca78/one/internal/config"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/spf13/viper"
+)
+
+// Provider abstracts the continuous integration service deploying the projects of the universes.
+// Every universe has a pipeline set named after its namespace, with a pipeline for each project
+type Provider interface {
+	// Config returns the configuration of the provider exposed by the api
+	Config() interface{}
+	// Repos returns the repositories with a pipeline
+	Repos() []string
+	// CreatePipelines creates the pipeline set of the universe and triggers the first builds, it returns the pipeline and the trigger of each project.
+	// The triggers of a provider not tracking its builds are nil, as for the other methods starting a build
+	CreatePipelines(jobsParams *pipeline.JobsParameters, namespace string) (map[string]string, map[string]*pipeline.Trigger, error)
+	// TriggerBuild starts a build of the pipeline of the repo on the commit of the build
+	TriggerBuild(repo, job, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error)
+	// Status returns the result of the last build of every pipeline of the universe
+	Status(namespace string) (pipeline.JobsStatuses, error)
+	// Builds returns the status of the pipeline of the repo with its last builds, the most recent first
+	Builds(repo, job, namespace string, limit int) (*pipeline.PipelineBuilds, error)
+	// Replay starts again the pipeline of the repo on the commit of the build
+	Replay(repo, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error)
+	// UpdatePipeline points the pipeline of the repo to another commit and triggers a build
+	UpdatePipeline(repo, job, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error)
+	// RenderPipeline renders the pipeline of the repo again after the stable flag of the universe changed
+	RenderPipeline(repo, job, namespace string, jobsParams *pipeline.JobsParameters) error
+	// Teardown deletes the pipeline set of the universe, a missing one is not an error
+	Teardown(namespace string) error
+	// PipelineSets returns the names of all the existing pipeline sets
+	PipelineSets() ([]string, error)
+}
+
+// LogStreamer is implemented by the providers able to stream the console output of a build
+type LogStreamer interface {
+	// StreamLog streams the lines of the build of the pipeline, the last build when build is empty.
+	// The channel is closed once the build ends or the context is done, the error is sent on the second channel
+	StreamLog(ctx context.Context, job, namespace, build string) (<-chan string, <-chan error)
+}
+
+// BuildTracker is implemented by the providers able to follow a trigger to the build it started
+type BuildTracker interface {
+	// ResolveTrigger updates a pending trigger, with the build number once the build is started
+	ResolveTrigger(trigger *pipeline.Trigger) error
+	// Build returns the build of the pipeline of the repo with the given number
+	Build(repo, job, namespace string, number int) (*pipeline.Build, error)
+}
+
+// ProviderSet is a switch that choose the configured continuous integration provider and initialize it
+func ProviderSet(v *viper.Viper) (Provider, error) {
+	switch provider := config.CheckAndGetString(v, "CI_PROVIDER"); provider {
+	case "jenkins":
+		return NewJenkinsProvider(v), nil
+	case "webhook":
+		return NewWebhookProvider(v), nil
+	default:
+		return nil, fmt.Errorf("no provider definition match %v", provider)
+	}
+}
@@ -0,0 +1,226 @@
+package ci
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/lzecca78/one/internal/config"
+	"github.com/lzecca78/one/internal/git"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// actions notified to the webhook
+const (
+	ActionCreate   = "create"
+	ActionTrigger  = "trigger"
+	ActionUpdate   = "update"
+	ActionRender   = "render"
+	ActionTeardown = "teardown"
+)
+
+// Event is the body posted to the webhook for every action on the pipelines of a universe
+type Event struct {
+	Action    string         `json:"action"`
+	Namespace string         `json:"namespace"`
+	Repo      string         `json:"repo,omitempty"`
+	Pipeline  string         `json:"pipeline,omitempty"`
+	Commit    *git.Commit    `json:"commit,omitempty"`
+	Commits   git.CommitSpec `json:"commits,omitempty"`
+	Stable    bool           `json:"stable"`
+	Profile   string         `json:"profile,omitempty"`
+	Partial   bool           `json:"partial,omitempty"`
+}
+
+// WebhookProvider delegates the pipelines to any ci system implementing the webhook:
+//
+//	POST <url>/events                 receives an Event
+//	GET  <url>/namespaces             returns the pipeline sets as a json list of namespaces
+//	GET  <url>/namespaces/<namespace> returns the result of the last build of each pipeline as a json object
+//	GET  <url>/namespaces/<namespace>/pipelines/<pipeline>?limit=<n> returns the last builds of the pipeline as a pipeline.PipelineBuilds
+//
+// Every request has the configured token as bearer authorization
+type WebhookProvider struct {
+	url    string
+	token  string
+	repos  []string
+	client *http.Client
+}
+
+type webhookConfig struct {
+	URL   string   `json:"url"`
+	Repos []string `json:"repos"`
+}
+
+// NewWebhookProvider initialize the webhook provider, the repositories are the ones of the conf section
+func NewWebhookProvider(v *viper.Viper) *WebhookProvider {
+	repos := []string{}
+	for repo := range v.GetStringMap("conf") {
+		repos = append(repos, repo)
+	}
+	return &WebhookProvider{
+		url:    config.CheckAndGetString(v, "CI_WEBHOOK_URL"),
+		token:  config.CheckAndGetString(v, "CI_WEBHOOK_TOKEN"),
+		repos:  repos,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *WebhookProvider) request(verb string, body interface{}, target interface{}, query url.Values, elem ...string) error {
+	uri, err := url.Parse(p.url)
+	if err != nil {
+		return errors.Wrap(err, "error while parsing webhook url")
+	}
+	uri.Path = path.Join(append([]string{uri.Path}, elem...)...)
+	uri.RawQuery = query.Encode()
+	var payload []byte
+	if body != nil {
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "error while converting webhook event to json")
+		}
+	}
+	request, err := http.NewRequest(verb, uri.String(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+p.token)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		r, _ := ioutil.ReadAll(response.Body)
+		return errors.Errorf("webhook %s %s responded %s: %s", verb, uri.Path, response.Status, string(r))
+	}
+	if target == nil {
+		return nil
+	}
+	err = json.NewDecoder(response.Body).Decode(target)
+	if err != nil {
+		return errors.Wrapf(err, "error while unmarshaling webhook %s response", uri.Path)
+	}
+	return nil
+}
+
+func (p *WebhookProvider) notify(event Event) error {
+	log.Printf("notifying webhook of %s for namespace %s", event.Action, event.Namespace)
+	return p.request(http.MethodPost, event, nil, nil, "events")
+}
+
+// Config returns the configuration of the webhook, without the token
+func (p *WebhookProvider) Config() interface{} {
+	return webhookConfig{URL: p.url, Repos: p.repos}
+}
+
+// Repos returns the repositories of the conf section
+func (p *WebhookProvider) Repos() []string {
+	return p.repos
+}
+
+// CreatePipelines notifies the creation of the universe, the pipelines are named after the namespace and the repo
+func (p *WebhookProvider) CreatePipelines(jobsParams *pipeline.JobsParameters, namespace string) (map[string]string, map[string]*pipeline.Trigger, error) {
+	err := p.notify(Event{
+		Action:    ActionCreate,
+		Namespace: namespace,
+		Commits:   jobsParams.CommitPerProject,
+		Stable:    jobsParams.Stable,
+		Profile:   jobsParams.Profile,
+		Partial:   jobsParams.Partial,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	pipelines := map[string]string{}
+	for repo := range jobsParams.CommitPerProject {
+		pipelines[repo] = pipeline.Name(repo, namespace)
+	}
+	return pipelines, nil, nil
+}
+
+// TriggerBuild notifies a build request of the pipeline on the commit, the builds of the webhook are not tracked
+func (p *WebhookProvider) TriggerBuild(repo, job, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
+	return nil, p.notify(Event{
+		Action:    ActionTrigger,
+		Namespace: namespace,
+		Repo:      repo,
+		Pipeline:  job,
+		Commit:    &build.Commit,
+	})
+}
+
+// Status returns the results reported by the webhook
+func (p *WebhookProvider) Status(namespace string) (pipeline.JobsStatuses, error) {
+	statuses := pipeline.JobsStatuses{}
+	err := p.request(http.MethodGet, nil, &statuses, nil, "namespaces", namespace)
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Builds returns the builds reported by the webhook
+func (p *WebhookProvider) Builds(repo, job, namespace string, limit int) (*pipeline.PipelineBuilds, error) {
+	builds := &pipeline.PipelineBuilds{}
+	query := url.Values{"limit": []string{strconv.Itoa(limit)}}
+	err := p.request(http.MethodGet, nil, builds, query, "namespaces", namespace, "pipelines", job)
+	if err != nil {
+		return nil, err
+	}
+	return builds, nil
+}
+
+// Replay notifies a build request of the pipeline of the repo
+func (p *WebhookProvider) Replay(repo, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
+	return p.TriggerBuild(repo, pipeline.Name(repo, namespace), namespace, build)
+}
+
+// UpdatePipeline notifies the new commit of the pipeline, the webhook is expected to build it
+func (p *WebhookProvider) UpdatePipeline(repo, job, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
+	return nil, p.notify(Event{
+		Action:    ActionUpdate,
+		Namespace: namespace,
+		Repo:      repo,
+		Pipeline:  job,
+		Commit:    &build.Commit,
+	})
+}
+
+// RenderPipeline notifies the new stable flag of the universe for the pipeline
+func (p *WebhookProvider) RenderPipeline(repo, job, namespace string, jobsParams *pipeline.JobsParameters) error {
+	return p.notify(Event{
+		Action:    ActionRender,
+		Namespace: namespace,
+		Repo:      repo,
+		Pipeline:  job,
+		Stable:    jobsParams.Stable,
+	})
+}
+
+// Teardown notifies the deletion of the universe
+func (p *WebhookProvider) Teardown(namespace string) error {
+	return p.notify(Event{
+		Action:    ActionTeardown,
+		Namespace: namespace,
+	})
+}
+
+// PipelineSets returns the namespaces known by the webhook
+func (p *WebhookProvider) PipelineSets() ([]string, error) {
+	namespaces := []string{}
+	err := p.request(http.MethodGet, nil, &namespaces, nil, "namespaces")
+	if err != nil {
+		return nil, errors.Wrap(err, "error while listing webhook namespaces")
+	}
+	return namespaces, nil
+}
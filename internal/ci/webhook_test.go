@@ -0,0 +1,65 @@
+package ci
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lzecca78/one/internal/git"
+	"github.com/lzecca78/one/internal/pipeline"
+)
+
+func TestWebhookProvider(t *testing.T) {
+	events := []Event{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/ci/events":
+			var event Event
+			json.NewDecoder(r.Body).Decode(&event)
+			events = append(events, event)
+		case r.Method == http.MethodGet && r.URL.Path == "/ci/namespaces/ms-test":
+			w.Write([]byte(`{"ms-test-repo1": "SUCCESS"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	provider := &WebhookProvider{url: server.URL + "/ci", token: "secret", client: server.Client()}
+
+	jobsParams := &pipeline.JobsParameters{CommitPerProject: git.CommitSpec{"repo1": {Branch: "master"}}}
+	pipelines, _, err := provider.CreatePipelines(jobsParams, "ms-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"repo1": "ms-test-repo1"}; !reflect.DeepEqual(pipelines, want) {
+		t.Errorf("expected pipelines %v, got %v", want, pipelines)
+	}
+	err = provider.Teardown("ms-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Action != ActionCreate || events[1].Action != ActionTeardown {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if events[0].Commits["repo1"].Branch != "master" {
+		t.Errorf("expected the commits in the create event, got %+v", events[0])
+	}
+
+	statuses, err := provider.Status("ms-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses["ms-test-repo1"] != "SUCCESS" {
+		t.Errorf("unexpected statuses %v", statuses)
+	}
+	_, err = provider.PipelineSets()
+	if err == nil {
+		t.Error("expected an error for a webhook not listing its namespaces")
+	}
+}
@@ -6,6 +6,21 @@ import (
 	"github.com/spf13/viper"
 )
 
+// defaults are the values of the optional keys, they keep the behavior of the versions without them
+var defaults = map[string]interface{}{
+	"NAME_TEMPLATE":      "ms-{{hash}}",
+	"DEFAULT_TTL":        "12h",
+	"MAX_TTL":            "72h",
+	"REAPER_INTERVAL":    "5m",
+	"LEADER_ELECTION":    false,
+	"SLEEPER_INTERVAL":   "1m",
+	"QUEUE_INTERVAL":     "1m",
+	"RECONCILE_INTERVAL": "15m",
+	"RECONCILE_REPAIR":   false,
+	"CI_PROVIDER":        "jenkins",
+	"TRACKER_INTERVAL":   "30s",
+}
+
 // GetConfig initialize all configuration from file and from environment variable
 func GetConfig() *viper.Viper {
 	v := viper.New()
@@ -15,6 +30,9 @@ func GetConfig() *viper.Viper {
 	v.AddConfigPath("/one")
 	v.AutomaticEnv()
 	v.SetEnvPrefix("ONE")
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
 	err := v.ReadInConfig()
 	if err != nil {
 		log.Fatalf("Fatal error config file: %s \n", err)
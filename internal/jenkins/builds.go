@@ -0,0 +1,172 @@
+package jenkins
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/pkg/errors"
+)
+
+// buildJSON is the json of a build returned by the jenkins api, limited to the fields of buildTree
+type buildJSON struct {
+	Number    int     `json:"number"`
+	Result    *string `json:"result"`
+	Building  bool    `json:"building"`
+	Timestamp int64   `json:"timestamp"`
+	Duration  int64   `json:"duration"`
+	Actions   []struct {
+		Causes []struct {
+			ShortDescription string `json:"shortDescription"`
+		} `json:"causes"`
+		LastBuiltRevision *struct {
+			SHA1 string `json:"SHA1"`
+		} `json:"lastBuiltRevision"`
+		RemoteURLs []string `json:"remoteUrls"`
+	} `json:"actions"`
+}
+
+// jobBuilds is the json returned by the jenkins api of a job, limited to the fields of jobTree
+type jobBuilds struct {
+	InQueue   bool `json:"inQueue"`
+	QueueItem *struct {
+		InQueueSince int64  `json:"inQueueSince"`
+		Why          string `json:"why"`
+	} `json:"queueItem"`
+	Builds []buildJSON `json:"builds"`
+}
+
+const buildTree = "number,result,building,timestamp,duration,actions[causes[shortDescription],lastBuiltRevision[SHA1],remoteUrls]"
+
+func jobTree(limit int) string {
+	return fmt.Sprintf("inQueue,queueItem[inQueueSince,why],builds[%s]{0,%d}", buildTree, limit)
+}
+
+func millis(value int64) time.Time {
+	return time.Unix(0, value*int64(time.Millisecond)).UTC()
+}
+
+// remoteRepo returns the name of the repository of a git remote url, e.g. repo1 for git@github.com:org/repo1.git
+func remoteRepo(remote string) string {
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+	return trimmed[strings.LastIndexAny(trimmed, "/:")+1:]
+}
+
+// toBuild converts the jenkins build to the build model, a running build has no result.
+// The sha is the one built from the remote of the repo, since a pipeline checks out its shared libraries too.
+// It is the only one built when the remotes are unknown
+func (b *buildJSON) toBuild(repo string) pipeline.Build {
+	build := pipeline.Build{
+		Number:    b.Number,
+		Result:    pipeline.BuildRunning,
+		StartedAt: millis(b.Timestamp),
+	}
+	if !b.Building {
+		build.Result = pipeline.BuildNotBuilt
+		if b.Result != nil {
+			build.Result = *b.Result
+		}
+		finishedAt := millis(b.Timestamp + b.Duration)
+		build.FinishedAt = &finishedAt
+		build.Duration = (time.Duration(b.Duration) * time.Millisecond).String()
+	}
+	causes := []string{}
+	revisions := []string{}
+	for _, action := range b.Actions {
+		for _, cause := range action.Causes {
+			causes = append(causes, cause.ShortDescription)
+		}
+		if action.LastBuiltRevision == nil {
+			continue
+		}
+		revisions = append(revisions, action.LastBuiltRevision.SHA1)
+		for _, remote := range action.RemoteURLs {
+			if remoteRepo(remote) == repo && build.Sha == "" {
+				build.Sha = action.LastBuiltRevision.SHA1
+			}
+		}
+	}
+	if build.Sha == "" && len(revisions) == 1 {
+		build.Sha = revisions[0]
+	}
+	build.Cause = strings.Join(causes, ", ")
+	return build
+}
+
+// toPipelineBuilds converts the jenkins job of the repo to the build model
+func (j *jobBuilds) toPipelineBuilds(repo, name string) *pipeline.PipelineBuilds {
+	pipelineBuilds := &pipeline.PipelineBuilds{
+		Pipeline: name,
+		Status:   pipeline.BuildNotBuilt,
+		Builds:   []pipeline.Build{},
+	}
+	for _, item := range j.Builds {
+		pipelineBuilds.Builds = append(pipelineBuilds.Builds, item.toBuild(repo))
+	}
+	if len(pipelineBuilds.Builds) > 0 {
+		pipelineBuilds.Status = pipelineBuilds.Builds[0].Result
+	}
+	// a queued build is about to replace the last one
+	if j.InQueue {
+		pipelineBuilds.Status = pipeline.BuildQueued
+		if j.QueueItem != nil {
+			queuedSince := millis(j.QueueItem.InQueueSince)
+			pipelineBuilds.QueuedSince = &queuedSince
+			pipelineBuilds.QueuedWhy = j.QueueItem.Why
+		}
+	}
+	return pipelineBuilds
+}
+
+// JobBuilds returns the status and the last builds of the job in the namespace folder, pipeline.ErrNotFound if the job does not exist
+func (c *JenkinsClient) JobBuilds(repo, namespace, newJobName string, limit int) (*pipeline.PipelineBuilds, error) {
+	uriPath := filepath.Join("job", namespace, "job", newJobName, "api", "json")
+	response, err := c.httpJenkinsClient(newJobName, uriPath, verbGet, nil, map[string]string{"tree": jobTree(limit)})
+	if err != nil {
+		log.Printf("error while getting builds of job %s: %v", newJobName, err)
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, pipeline.ErrNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error while getting builds of job %s: %s", newJobName, response.Status)
+	}
+	var job jobBuilds
+	err = json.NewDecoder(response.Body).Decode(&job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while unmarshaling builds of job %s", newJobName)
+	}
+	return job.toPipelineBuilds(repo, newJobName), nil
+}
+
+// JobBuild returns the build of the job with the given number, pipeline.ErrNotFound if the job or the build do not exist
+func (c *JenkinsClient) JobBuild(repo, namespace, newJobName string, number int) (*pipeline.Build, error) {
+	uriPath := filepath.Join("job", namespace, "job", newJobName, strconv.Itoa(number), "api", "json")
+	response, err := c.httpJenkinsClient(newJobName, uriPath, verbGet, nil, map[string]string{"tree": buildTree})
+	if err != nil {
+		log.Printf("error while getting build %d of job %s: %v", number, newJobName, err)
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, pipeline.ErrNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error while getting build %d of job %s: %s", number, newJobName, response.Status)
+	}
+	var item buildJSON
+	err = json.NewDecoder(response.Body).Decode(&item)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while unmarshaling build %d of job %s", number, newJobName)
+	}
+	build := item.toBuild(repo)
+	return &build, nil
+}
@@ -11,9 +11,11 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"strconv"
 
 	"github.com/lzecca78/one/internal/config"
 	"github.com/lzecca78/one/internal/git"
+	"github.com/lzecca78/one/internal/pipeline"
 	"github.com/bndr/gojenkins"
 	retryablehttp "github.com/hashicorp/go-retryablehttp"
 	"github.com/jbowtie/gokogiri"
@@ -30,8 +32,10 @@ const (
 	cvsXpath          = "/flow-definition/definition/scm/branches/hudson.plugins.git.BranchSpec"
 	k8sEnvAttr        = "K8S_NAMESPACE"
 	gitBranchEnvAttr  = "GIT_BRANCH"
+	gitShaEnvAttr     = "GIT_SHA"
 	nameXpath         = "name"
 	defaultValueXpath = "defaultValue"
+	folderClass       = "com.cloudbees.hudson.plugins.folder.Folder"
 )
 
 //JenkinsClient struct with config inherited from JenkinsClientConfig and native client of gojenkins
@@ -40,25 +44,19 @@ type JenkinsClient struct {
 	Client *gojenkins.Jenkins
 }
 
-//JenkinsJobConfig is a struct that describe the needed element for implementing the jenkins api calls
+//JenkinsJobConfig is a struct that describe the needed element for implementing the jenkins api calls.
+//The names of the namespace, branch and sha parameters default to K8S_NAMESPACE, GIT_BRANCH and GIT_SHA, the branch value to refs/heads/<branch>.
+//PinSha builds the requested sha instead of the head of the branch
 type JenkinsJobConfig struct {
-	JenkinsJob   string `json:"jenkinsJob,omitempty" yaml:"jenkinsJob,omitempty" mapstructure:"jenkinsJob,omitempty"`
-	JenkinsToken string `json:"jenkinsToken,omitempty" yaml:"jenkinsToken,omitempty" mapstructure:"jenkinsToken,omitempty"`
-}
-
-//JobsParameters is the struct needed by a continuous integration service to configure parametrized jobs
-type JobsParameters struct {
-	Stable           bool
-	CommitPerProject git.CommitSpec
-}
-
-type JobsStatuses map[string]string
-
-type ContinousIntegration interface {
-	User() string
-	Password() string
-	Url() string
-	JobsWithParameters() *JobsParameters
+	JenkinsJob         string         `json:"jenkinsJob,omitempty" yaml:"jenkinsJob,omitempty" mapstructure:"jenkinsJob,omitempty"`
+	JenkinsToken       string         `json:"jenkinsToken,omitempty" yaml:"jenkinsToken,omitempty" mapstructure:"jenkinsToken,omitempty"`
+	NamespaceParameter string         `json:"namespaceParameter,omitempty" yaml:"namespaceParameter,omitempty" mapstructure:"namespaceParameter,omitempty"`
+	BranchParameter    string         `json:"branchParameter,omitempty" yaml:"branchParameter,omitempty" mapstructure:"branchParameter,omitempty"`
+	BranchValue        string         `json:"branchValue,omitempty" yaml:"branchValue,omitempty" mapstructure:"branchValue,omitempty"`
+	Parameters         []JobParameter `json:"parameters,omitempty" yaml:"parameters,omitempty" mapstructure:"parameters,omitempty"`
+	XMLRules           []XMLRule      `json:"xmlRules,omitempty" yaml:"xmlRules,omitempty" mapstructure:"xmlRules,omitempty"`
+	PinSha             bool           `json:"pinSha,omitempty" yaml:"pinSha,omitempty" mapstructure:"pinSha,omitempty"`
+	ShaParameter       string         `json:"shaParameter,omitempty" yaml:"shaParameter,omitempty" mapstructure:"shaParameter,omitempty"`
 }
 
 type JenkinsItem struct {
@@ -115,7 +113,11 @@ func NewJenkinsClient(v *viper.Viper) *JenkinsClient {
 		log.Fatal("jenkins client failed with ", err)
 	}
 	repos := []string{}
-	for repo := range repositoriesProperties.Conf {
+	for repo, conf := range repositoriesProperties.Conf {
+		err = conf.validate()
+		if err != nil {
+			log.Fatalf("invalid configuration of repo %s: %v", repo, err)
+		}
 		repos = append(repos, repo)
 	}
 
@@ -127,53 +129,33 @@ func (c *JenkinsClient) GetRepos() []string {
 	return c.Config.repos
 }
 
-func (c *JenkinsClient) GetJobStatus(namespace string) (JobsStatuses, error) {
-	statuses := JobsStatuses{}
+//GetJobStatus returns the status of the job of every repository in the namespace, the repositories without a job are skipped
+func (c *JenkinsClient) GetJobStatus(namespace string) (pipeline.JobsStatuses, error) {
+	statuses := pipeline.JobsStatuses{}
 	repoProp := c.Config.RepositoriesProperties
 
 	for job := range repoProp.Conf {
-		newJobName := GetNewJobName(job, namespace)
-		folderSubPath := fmt.Sprintf("job/%s/job", namespace)
-		getURIPath := filepath.Join(folderSubPath, newJobName, "lastBuild", "api", "json")
-		getResponse, err := c.httpJenkinsClient(newJobName, getURIPath, verbGet, nil, nil)
-		log.Printf("the response body for job %s is %v", job, getResponse)
-		if err != nil {
-			log.Printf("error while getting response : %s", err)
-			return nil, err
+		newJobName := pipeline.Name(job, namespace)
+		builds, err := c.JobBuilds(job, namespace, newJobName, 1)
+		if err == pipeline.ErrNotFound {
+			continue
 		}
-		var jsonResp map[string]interface{}
-		respRead, err := ioutil.ReadAll(getResponse.Body)
 		if err != nil {
-			log.Printf("error while reading response :%s", err)
 			return nil, err
 		}
-		err = json.Unmarshal(respRead, &jsonResp)
-		if err != nil {
-			log.Printf("error while unmarshaling json :%v", err)
-		}
-		for k, v := range jsonResp {
-			if k == "result" {
-				var status string
-				if v == nil {
-					status = "RUNNING"
-				} else {
-					status = v.(string)
-				}
-				statuses[newJobName] = status
-			}
-		}
+		statuses[newJobName] = builds.Status
 	}
 	log.Printf("statuses is %v", statuses)
 	return statuses, nil
 }
 
-func (c *JenkinsClient) createFolder(cloneFolder, namespace string, jobSpec *JobsParameters) (string, error) {
+func (c *JenkinsClient) createFolder(cloneFolder, namespace string, jobSpec *pipeline.JobsParameters) (string, error) {
 	folder := namespace
 	jItem := JenkinsItem{
 		Folder: true,
 		Job:    false,
 	}
-	bytesXML, err := c.getItemFromJenkins(namespace, cloneFolder, jobSpec, jItem)
+	bytesXML, err := c.getItemFromJenkins(namespace, cloneFolder, "", jobSpec, jItem)
 	if err != nil {
 		log.Printf("error while parsing xml: %v", err)
 		return "", errors.Errorf("error while parsing xml: %v", err)
@@ -192,32 +174,31 @@ func (c *JenkinsClient) createFolder(cloneFolder, namespace string, jobSpec *Job
 	return folder, nil
 }
 
-func (c *JenkinsClient) ReplayJob(job, repo, branch, namespace string) error {
+//ReplayJob triggers again the job of the repo in the namespace, it returns the trigger of the new build
+func (c *JenkinsClient) ReplayJob(job, repo, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
 	log.Printf("entering in the function ReplayJob")
-	jobToken := c.Config.RepositoriesProperties.Conf[repo].JenkinsToken
-	log.Printf("executed JenkinsToken")
-	newJobName := GetNewJobName(job, namespace)
+	newJobName := pipeline.Name(job, namespace)
 	log.Printf("newJobName is %s", newJobName)
-	err := c.executeJob(repo, namespace, jobToken, newJobName, branch)
+	trigger, err := c.executeJob(repo, namespace, newJobName, build)
 	log.Printf("executeJob is executed")
 	if err != nil {
 		log.Printf("error executing job : %s for repo : %s with ", namespace, err)
-		return err
+		return nil, err
 	}
-	return nil
+	return trigger, nil
 }
 
-func (c *JenkinsClient) createJob(job, namespace string, jobSpec *JobsParameters) (string, error) {
+func (c *JenkinsClient) createJob(job, repo, namespace string, jobSpec *pipeline.JobsParameters) (string, error) {
 	jItem := JenkinsItem{
 		Folder: false,
 		Job:    true,
 	}
-	bytesXML, err := c.getItemFromJenkins(namespace, job, jobSpec, jItem)
+	bytesXML, err := c.getItemFromJenkins(namespace, job, repo, jobSpec, jItem)
 	if err != nil {
 		log.Printf("error while parsing xml: %v", err)
 		return "", errors.Errorf("error while parsing xml: %v", err)
 	}
-	newNameJob := GetNewJobName(job, namespace)
+	newNameJob := pipeline.Name(job, namespace)
 	folderSubPath := fmt.Sprintf("job/%s", namespace)
 	postContextPath := filepath.Join(folderSubPath, "createItem")
 	postResponse, err := c.httpJenkinsClient(job, postContextPath, verbPost, bytes.NewBuffer(bytesXML), map[string]string{"name": newNameJob})
@@ -233,7 +214,8 @@ func (c *JenkinsClient) createJob(job, namespace string, jobSpec *JobsParameters
 	return newNameJob, nil
 }
 
-func (c *JenkinsClient) getItemFromJenkins(namespace, projectScope string, jobSpec *JobsParameters, jitem JenkinsItem) ([]byte, error) {
+//getItemFromJenkins returns the config.xml of the item projectScope rendered for the repo, an empty repo for a folder
+func (c *JenkinsClient) getItemFromJenkins(namespace, projectScope, repo string, jobSpec *pipeline.JobsParameters, jitem JenkinsItem) ([]byte, error) {
 	getURIPath := filepath.Join("job", projectScope, "config.xml")
 	getResponse, err := c.httpJenkinsClient(projectScope, getURIPath, verbGet, nil, nil)
 	if err != nil {
@@ -244,10 +226,12 @@ func (c *JenkinsClient) getItemFromJenkins(namespace, projectScope string, jobSp
 		log.Printf("response status conewJobName is not 200 : %v", getResponse.StatusCode)
 		return nil, errors.Errorf("the response was not ok! : %v", getResponse.StatusCode)
 	}
-	return parseXMLBody(namespace, projectScope, getResponse, jobSpec, jitem)
+	return parseXMLBody(namespace, repo, c.Config.RepositoriesProperties.Conf[repo], getResponse, jobSpec, jitem)
 }
 
-func parseXMLBody(namespace, project string, response *http.Response, jobSpec *JobsParameters, item JenkinsItem) ([]byte, error) {
+//parseXMLBody rewrites the parameters and the branch spec of the job of the repo, or removes its triggers for a stable universe.
+//The xml rules are applied to every job
+func parseXMLBody(namespace, project string, conf JenkinsJobConfig, response *http.Response, jobSpec *pipeline.JobsParameters, item JenkinsItem) ([]byte, error) {
 	var resp []byte
 	xmlResponse, err := ioutil.ReadAll(response.Body)
 	defer response.Body.Close()
@@ -267,20 +251,32 @@ func parseXMLBody(namespace, project string, response *http.Response, jobSpec *J
 	}
 	if (!jobSpec.Stable) && (item.Job) {
 		k8sEnvCurrentValue := namespace
-		gitBranchCurrentValue := jobSpec.CommitPerProject[project].Branch
+		commit := jobSpec.CommitPerProject[project]
+		gitBranchCurrentValue := commit.Branch
+		// a pinned job checks out the sha instead of the head of the branch
+		branchSpecValue := gitBranchCurrentValue
+		if conf.pinned(commit) {
+			branchSpecValue = commit.Sha
+		}
 		envs, err := parsedXML.Root().Search(envsXpath)
 		if err != nil {
 			log.Printf("error searching for triggers in %s: %v", triggersXpath, err)
 			return resp, err
 		}
 		for _, env := range envs {
-			err := changeDefaultValueForSpecificName(env, k8sEnvAttr, k8sEnvCurrentValue, nameXpath, defaultValueXpath)
+			err := changeDefaultValueForSpecificName(env, conf.namespaceParameter(), k8sEnvCurrentValue, nameXpath, defaultValueXpath)
 			if err != nil {
-				log.Printf("error while changing value %s for key %s: %v", k8sEnvCurrentValue, k8sEnvAttr, err)
+				log.Printf("error while changing value %s for key %s: %v", k8sEnvCurrentValue, conf.namespaceParameter(), err)
 			}
-			err = changeDefaultValueForSpecificName(env, gitBranchEnvAttr, gitBranchCurrentValue, nameXpath, defaultValueXpath)
+			err = changeDefaultValueForSpecificName(env, conf.branchParameter(), gitBranchCurrentValue, nameXpath, defaultValueXpath)
 			if err != nil {
-				log.Printf("error while changing value %s for key %s: %v", gitBranchCurrentValue, gitBranchEnvAttr, err)
+				log.Printf("error while changing value %s for key %s: %v", gitBranchCurrentValue, conf.branchParameter(), err)
+			}
+			if conf.pinned(commit) {
+				err = changeDefaultValueForSpecificName(env, conf.shaParameter(), commit.Sha, nameXpath, defaultValueXpath)
+				if err != nil {
+					log.Printf("error while changing value %s for key %s: %v", commit.Sha, conf.shaParameter(), err)
+				}
 			}
 		}
 		cvsBlocks, err := parsedXML.Root().Search(cvsXpath)
@@ -288,12 +284,19 @@ func parseXMLBody(namespace, project string, response *http.Response, jobSpec *J
 			log.Printf("error searching for envsBlock in %s: %v", envsXpath, err)
 		}
 		for _, cvs := range cvsBlocks {
-			err := replaceBranchSpec(cvs, "name", gitBranchCurrentValue, nameXpath)
+			err := replaceBranchSpec(cvs, "name", branchSpecValue, nameXpath)
 			if err != nil {
-				log.Printf("error while changing value for name %s: %v", gitBranchCurrentValue, err)
+				log.Printf("error while changing value for name %s: %v", branchSpecValue, err)
 			}
 		}
 	}
+	if item.Job {
+		err = conf.applyXMLRules(parsedXML.Root(), newTemplateData(namespace, jobSpec.BuildContext(project)))
+		if err != nil {
+			log.Printf("error while applying xml rules of %s: %v", project, err)
+			return resp, err
+		}
+	}
 
 	if jobSpec.Stable {
 		triggers, err := parsedXML.Root().Search(triggersXpath)
@@ -309,53 +312,119 @@ func parseXMLBody(namespace, project string, response *http.Response, jobSpec *J
 	return bytesXML, nil
 }
 
-//GetNewJobName return the name of the job with the namespace prefix
-func GetNewJobName(job, namespace string) string {
-	return fmt.Sprintf("%s-%s", namespace, job)
-}
-
-//ConfigureJobs is a function that implements JenkinsClient, takes JobsParameters and return ....
-func (c *JenkinsClient) ConfigureJobs(j *JobsParameters, namespace string) (map[string]string, error) {
+//ConfigureJobs is a function that implements JenkinsClient, takes JobsParameters and return the job of each repo with the trigger of its first build.
+//A build that can not be triggered fails the configuration, so the universe is not reported as created
+func (c *JenkinsClient) ConfigureJobs(j *pipeline.JobsParameters, namespace string) (map[string]string, map[string]*pipeline.Trigger, error) {
 	response := map[string]string{}
+	triggers := map[string]*pipeline.Trigger{}
 	folderTemplateName := c.Config.FolderTemplate
 	_, err := c.createFolder(folderTemplateName, namespace, j)
 	if err != nil {
 		log.Printf("error creating folder with name %s: %v", namespace, err)
-		return nil, err
+		return nil, nil, err
 	}
 	for repo, commit := range j.CommitPerProject {
 		jobName := c.Config.RepositoriesProperties.Conf[repo].JenkinsJob
-		jobToken := c.Config.RepositoriesProperties.Conf[repo].JenkinsToken
-		log.Println("jobName: ", jobName, "jobToken: ", jobToken, "commit: ", commit)
-		newJobName, err := c.createJob(jobName, namespace, j)
+		log.Println("jobName: ", jobName, "commit: ", commit)
+		newJobName, err := c.createJob(jobName, repo, namespace, j)
 		response[repo] = newJobName
 		if err != nil {
 			log.Printf("error creating job %s from %s : %v", newJobName, jobName, err)
-			return nil, err
+			return nil, nil, err
 		}
 		response[repo] = newJobName
-		log.Println("jobName: ", jobName, "jobToken: ", jobToken, "commit: ", commit)
-		branch := commit.Branch
-		err = c.executeJob(repo, namespace, jobToken, newJobName, branch)
+		trigger, err := c.executeJob(repo, namespace, newJobName, j.BuildContext(repo))
 		if err != nil {
-			log.Printf("error executing job : %s for repo : %s with ", namespace, err)
+			log.Printf("error executing job %s for repo %s: %v", newJobName, repo, err)
+			return nil, nil, errors.Wrapf(err, "error while triggering job %s", newJobName)
 		}
+		triggers[repo] = trigger
+	}
+	return response, triggers, nil
+}
+
+//BuildJob triggers a build of the job created for the repo in the namespace, on the commit of the build
+func (c *JenkinsClient) BuildJob(repo, newJobName, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
+	return c.executeJob(repo, namespace, newJobName, build)
+}
 
+//executeJob triggers the job with the build parameters configured for the repo
+func (c *JenkinsClient) executeJob(repo, namespace, job string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
+	parameters, err := c.Config.RepositoriesProperties.Conf[repo].buildParameters(namespace, build)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while rendering the parameters of job %s", job)
 	}
-	return response, nil
+	return c.jenkinsRequest(parameters, job, namespace)
+}
+
+//UpdateJob rewrites the branch of the job created for the repo in the namespace, with the same edits done at creation, and triggers a new build
+func (c *JenkinsClient) UpdateJob(repo, newJobName, namespace string, build pipeline.BuildContext) (*pipeline.Trigger, error) {
+	configPath := filepath.Join("job", namespace, "job", newJobName, "config.xml")
+	getResponse, err := c.httpJenkinsClient(newJobName, configPath, verbGet, nil, nil)
+	if err != nil {
+		log.Printf("error while getting response : %s", err)
+		return nil, err
+	}
+	if getResponse.StatusCode != 200 {
+		log.Printf("response status code of job %s is not 200 : %v", newJobName, getResponse.StatusCode)
+		return nil, errors.Errorf("the response was not ok! : %v", getResponse.StatusCode)
+	}
+	// the job of a stable universe has no triggers already, only the branch is rewritten
+	jobSpec := &pipeline.JobsParameters{
+		CommitPerProject: git.CommitSpec{repo: build.Commit},
+		Owner:            build.Owner,
+		Hosts:            map[string][]string{repo: build.Hosts},
+	}
+	bytesXML, err := parseXMLBody(namespace, repo, c.Config.RepositoriesProperties.Conf[repo], getResponse, jobSpec, JenkinsItem{Job: true})
+	if err != nil {
+		log.Printf("error while parsing xml: %v", err)
+		return nil, errors.Errorf("error while parsing xml: %v", err)
+	}
+	postResponse, err := c.httpJenkinsClient(newJobName, configPath, verbPost, bytes.NewBuffer(bytesXML), nil)
+	if err != nil {
+		log.Printf("error in post:%s", err)
+		return nil, err
+	}
+	if postResponse.StatusCode != 200 {
+		r, _ := ioutil.ReadAll(postResponse.Body)
+		log.Printf("the update of job %s was not good : %v, %v", newJobName, postResponse.StatusCode, string(r))
+		return nil, errors.Errorf("the response was not ok! : %v", postResponse.StatusCode)
+	}
+	return c.executeJob(repo, namespace, newJobName, build)
 }
 
-func (c *JenkinsClient) executeJob(repo, namespace, token, job, branch string) error {
-	parameters := map[string]string{
-		"GIT_BRANCH":    "refs/heads/" + branch,
-		"K8S_NAMESPACE": namespace,
-		"token":         token,
-		"cause":         "build by one, deploying to ns: " + namespace,
+//RenderJob re-renders the job created for the repo in the namespace after the stable flag of the universe changed.
+//A stable job is the current one without the scm triggers, a not stable job is rendered again from the template job to restore them
+func (c *JenkinsClient) RenderJob(repo, newJobName, namespace string, jobSpec *pipeline.JobsParameters) error {
+	configPath := filepath.Join("job", namespace, "job", newJobName, "config.xml")
+	sourcePath := configPath
+	if !jobSpec.Stable {
+		sourcePath = filepath.Join("job", c.Config.RepositoriesProperties.Conf[repo].JenkinsJob, "config.xml")
 	}
-	err := c.jenkinsRequest(parameters, job, namespace)
+	getResponse, err := c.httpJenkinsClient(newJobName, sourcePath, verbGet, nil, nil)
 	if err != nil {
+		log.Printf("error while getting response : %s", err)
 		return err
 	}
+	if getResponse.StatusCode != 200 {
+		log.Printf("response status code of %s is not 200 : %v", sourcePath, getResponse.StatusCode)
+		return errors.Errorf("the response was not ok! : %v", getResponse.StatusCode)
+	}
+	bytesXML, err := parseXMLBody(namespace, repo, c.Config.RepositoriesProperties.Conf[repo], getResponse, jobSpec, JenkinsItem{Job: true})
+	if err != nil {
+		log.Printf("error while parsing xml: %v", err)
+		return errors.Errorf("error while parsing xml: %v", err)
+	}
+	postResponse, err := c.httpJenkinsClient(newJobName, configPath, verbPost, bytes.NewBuffer(bytesXML), nil)
+	if err != nil {
+		log.Printf("error in post:%s", err)
+		return err
+	}
+	if postResponse.StatusCode != 200 {
+		r, _ := ioutil.ReadAll(postResponse.Body)
+		log.Printf("the update of job %s was not good : %v, %v", newJobName, postResponse.StatusCode, string(r))
+		return errors.Errorf("the response was not ok! : %v", postResponse.StatusCode)
+	}
 	return nil
 }
 
@@ -364,20 +433,96 @@ func (c *JenkinsClient) DeleteFolder(folderName string) error {
 	log.Printf("deleting job %v", folderName)
 	uriPath := filepath.Join("job", folderName, "doDelete")
 	response, err := c.httpJenkinsClient(folderName, uriPath, verbPost, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
 	log.Println(response)
-	return err
+	// the folder is already gone
+	if response.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	// jenkins redirects to the parent folder once deleted
+	if response.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("error while deleting folder %s: %s", folderName, response.Status)
+	}
+	return nil
+}
+
+// ListFolders returns the names of the folders in the root of jenkins
+func (c *JenkinsClient) ListFolders() ([]string, error) {
+	uriPath := filepath.Join("api", "json")
+	qs := map[string]string{"tree": "jobs[name]"}
+	response, err := c.httpJenkinsClient("", uriPath, verbGet, nil, qs)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error while listing folders: %s", response.Status)
+	}
+	var root struct {
+		Jobs []struct {
+			Name  string `json:"name"`
+			Class string `json:"_class"`
+		} `json:"jobs"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&root)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while unmarshaling jenkins jobs")
+	}
+	folders := []string{}
+	for _, job := range root.Jobs {
+		if job.Class == folderClass {
+			folders = append(folders, job.Name)
+		}
+	}
+	return folders, nil
 }
 
-//jenkinsRequest is a wrapper for an httpClient that send a post to Jenkins with the job params in the payload
-func (c *JenkinsClient) jenkinsRequest(parameters map[string]string, jobName, namespace string) error {
+//ProgressiveText fetches the console output of a build of the job from the start offset, build is a number or lastBuild.
+//It returns the text, the offset of the next fetch and true while the build is running
+func (c *JenkinsClient) ProgressiveText(namespace, newJobName, build string, start int64) (string, int64, bool, error) {
+	uriPath := filepath.Join("job", namespace, "job", newJobName, build, "logText", "progressiveText")
+	qs := map[string]string{"start": strconv.FormatInt(start, 10)}
+	response, err := c.httpJenkinsClient(newJobName, uriPath, verbGet, nil, qs)
+	if err != nil {
+		return "", start, false, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", start, false, errors.Errorf("error while getting console of build %s of job %s: %s", build, newJobName, response.Status)
+	}
+	text, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", start, false, err
+	}
+	next := start
+	if size := response.Header.Get("X-Text-Size"); size != "" {
+		next, err = strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return "", start, false, errors.Wrapf(err, "invalid X-Text-Size %s", size)
+		}
+	}
+	return string(text), next, response.Header.Get("X-More-Data") == "true", nil
+}
+
+//jenkinsRequest is a wrapper for an httpClient that send a post to Jenkins with the job params in the payload.
+//It returns the trigger of the queue item in the Location header of the response
+func (c *JenkinsClient) jenkinsRequest(parameters map[string]string, jobName, namespace string) (*pipeline.Trigger, error) {
 	folderSubPath := fmt.Sprintf("job/%s/job", namespace)
 	uriPath := filepath.Join(folderSubPath, jobName, "buildWithParameters")
 	response, err := c.httpJenkinsClient(jobName, uriPath, verbPost, nil, parameters)
 	if err != nil {
 		log.Printf("there was en error in the response for uri %s, verb %s: %v", uriPath, verbPost, err)
+		return nil, err
 	}
+	defer response.Body.Close()
 	log.Println(response)
-	return err
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, errors.Errorf("error while building job %s: %s", jobName, response.Status)
+	}
+	return newTrigger(response.Header.Get("Location")), nil
 }
 
 func (c *JenkinsClient) httpJenkinsClient(jobName, uri, verb string, payload io.Reader, qs map[string]string) (*http.Response, error) {
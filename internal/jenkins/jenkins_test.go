@@ -2,6 +2,8 @@ package jenkins
 
 import (
 	"testing"
+
+	"github.com/lzecca78/one/internal/pipeline"
 )
 
 func TestNewJenkinsClient(t *testing.T) {
@@ -15,7 +17,7 @@ func TestConfigureJobs(t *testing.T) {
 	branch := "brainfuck"
 	v, repoProperties := GetConfig()
 	jenkinsclient := NewJenkinsClient(v, repoProperties)
-	jobsParams := &JobsParameters{
+	jobsParams := &pipeline.JobsParameters{
 		CommitPerProject: CICommitSpec{
 			repoName: Commit{Sha: sha, Branch: branch}},
 	}
@@ -0,0 +1,188 @@
+package jenkins
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/lzecca78/one/internal/git"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/jbowtie/gokogiri/xml"
+	"github.com/pkg/errors"
+)
+
+// defaultBranchValue is the value of the branch parameter when the repo does not configure one
+const defaultBranchValue = "refs/heads/{{.Branch}}"
+
+// sampleData is the data the templates are executed against at startup, so a misspelled field is detected before any build
+var sampleData = templateData{
+	Namespace: "ms-sample",
+	Branch:    "master",
+	Sha:       "0000000000000000000000000000000000000000",
+	Owner:     "owner",
+	Hosts:     []string{"sample.example.com"},
+}
+
+// templateFuncs are the functions available to the templates of the job configuration besides the builtin ones
+var templateFuncs = template.FuncMap{"join": strings.Join}
+
+//JobParameter is an additional build parameter of a job, the value is a template of the BuildContext of the build
+type JobParameter struct {
+	Name  string `json:"name" yaml:"name" mapstructure:"name"`
+	Value string `json:"value" yaml:"value" mapstructure:"value"`
+}
+
+//XMLRule rewrites the nodes of the config.xml of a job matching XPath: their content is replaced by the template Replace, or they are deleted with Remove
+type XMLRule struct {
+	XPath   string `json:"xpath" yaml:"xpath" mapstructure:"xpath"`
+	Replace string `json:"replace,omitempty" yaml:"replace,omitempty" mapstructure:"replace,omitempty"`
+	Remove  bool   `json:"remove,omitempty" yaml:"remove,omitempty" mapstructure:"remove,omitempty"`
+}
+
+//templateData is the data available to the templates of the job configuration, e.g. {{.Branch}} or {{join .Hosts ","}}
+type templateData struct {
+	Namespace string
+	Branch    string
+	Sha       string
+	Owner     string
+	Hosts     []string
+}
+
+func newTemplateData(namespace string, build pipeline.BuildContext) templateData {
+	return templateData{
+		Namespace: namespace,
+		Branch:    build.Commit.Branch,
+		Sha:       build.Commit.Sha,
+		Owner:     build.Owner,
+		Hosts:     build.Hosts,
+	}
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+func render(text string, data templateData) (string, error) {
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, data)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (conf JenkinsJobConfig) namespaceParameter() string {
+	if conf.NamespaceParameter != "" {
+		return conf.NamespaceParameter
+	}
+	return k8sEnvAttr
+}
+
+func (conf JenkinsJobConfig) branchParameter() string {
+	if conf.BranchParameter != "" {
+		return conf.BranchParameter
+	}
+	return gitBranchEnvAttr
+}
+
+func (conf JenkinsJobConfig) shaParameter() string {
+	if conf.ShaParameter != "" {
+		return conf.ShaParameter
+	}
+	return gitShaEnvAttr
+}
+
+//pinned check if the builds of the commit are pinned to its sha, a commit without sha follows the branch
+func (conf JenkinsJobConfig) pinned(commit git.Commit) bool {
+	return conf.PinSha && commit.Sha != ""
+}
+
+func (conf JenkinsJobConfig) branchValue() string {
+	if conf.BranchValue != "" {
+		return conf.BranchValue
+	}
+	return defaultBranchValue
+}
+
+//validate checks the xml rules of the repo and executes its templates against the sample data
+func (conf JenkinsJobConfig) validate() error {
+	texts := []string{conf.branchValue()}
+	for _, parameter := range conf.Parameters {
+		if parameter.Name == "" {
+			return errors.Errorf("parameter without name")
+		}
+		texts = append(texts, parameter.Value)
+	}
+	for _, rule := range conf.XMLRules {
+		if rule.XPath == "" {
+			return errors.Errorf("xml rule without xpath")
+		}
+		if rule.Remove == (rule.Replace != "") {
+			return errors.Errorf("xml rule %s must either replace or remove", rule.XPath)
+		}
+		texts = append(texts, rule.Replace)
+	}
+	for _, text := range texts {
+		_, err := render(text, sampleData)
+		if err != nil {
+			return errors.Wrapf(err, "invalid template %s", text)
+		}
+	}
+	return nil
+}
+
+//buildParameters returns the parameters of a build of the job of the repo, the configured ones override the default ones
+func (conf JenkinsJobConfig) buildParameters(namespace string, build pipeline.BuildContext) (map[string]string, error) {
+	data := newTemplateData(namespace, build)
+	branch, err := render(conf.branchValue(), data)
+	if err != nil {
+		return nil, err
+	}
+	parameters := map[string]string{
+		conf.branchParameter():    branch,
+		conf.namespaceParameter(): namespace,
+		"token":                   conf.JenkinsToken,
+		"cause":                   "build by one, deploying to ns: " + namespace,
+	}
+	if conf.pinned(build.Commit) {
+		parameters[conf.shaParameter()] = build.Commit.Sha
+	}
+	for _, parameter := range conf.Parameters {
+		parameters[parameter.Name], err = render(parameter.Value, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error while rendering parameter %s", parameter.Name)
+		}
+	}
+	return parameters, nil
+}
+
+//applyXMLRules rewrites the config.xml of the job of the repo with its xml rules
+func (conf JenkinsJobConfig) applyXMLRules(root xml.Node, data templateData) error {
+	for _, rule := range conf.XMLRules {
+		nodes, err := root.Search(rule.XPath)
+		if err != nil {
+			return errors.Wrapf(err, "error searching for %s", rule.XPath)
+		}
+		if rule.Remove {
+			for _, node := range nodes {
+				node.Remove()
+			}
+			continue
+		}
+		content, err := render(rule.Replace, data)
+		if err != nil {
+			return errors.Wrapf(err, "error while rendering xml rule %s", rule.XPath)
+		}
+		for _, node := range nodes {
+			err = node.SetContent(content)
+			if err != nil {
+				return errors.Wrapf(err, "error while replacing %s", rule.XPath)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package jenkins
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/pkg/errors"
+)
+
+// queueItemID parses the id of the queue item from the location returned by buildWithParameters, e.g. https://ci/queue/item/42/
+func queueItemID(location string) (int64, error) {
+	trimmed := strings.TrimSuffix(location, "/")
+	if !strings.HasSuffix(path.Dir(trimmed), "queue/item") {
+		return 0, errors.Errorf("location %s is not a queue item", location)
+	}
+	return strconv.ParseInt(path.Base(trimmed), 10, 64)
+}
+
+// newTrigger returns the trigger of the queue item in the location, lost if it is not a queue item
+func newTrigger(location string) *pipeline.Trigger {
+	trigger := &pipeline.Trigger{State: pipeline.TriggerQueued, TriggeredAt: time.Now().UTC()}
+	id, err := queueItemID(location)
+	if err != nil {
+		trigger.State = pipeline.TriggerLost
+		return trigger
+	}
+	trigger.QueueItem = id
+	return trigger
+}
+
+// ResolveTrigger looks up the queue item of a pending trigger, setting the build number once started.
+// Jenkins forgets the queue items a few minutes after they leave the queue, the trigger is lost if not resolved before
+func (c *JenkinsClient) ResolveTrigger(trigger *pipeline.Trigger) error {
+	if !trigger.Pending() {
+		return nil
+	}
+	id := strconv.FormatInt(trigger.QueueItem, 10)
+	uriPath := filepath.Join("queue", "item", id, "api", "json")
+	response, err := c.httpJenkinsClient(id, uriPath, verbGet, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		trigger.State = pipeline.TriggerLost
+		return nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("error while getting queue item %s: %s", id, response.Status)
+	}
+	var item struct {
+		Cancelled  bool `json:"cancelled"`
+		Executable *struct {
+			Number int `json:"number"`
+		} `json:"executable"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&item)
+	if err != nil {
+		return errors.Wrapf(err, "error while unmarshaling queue item %s", id)
+	}
+	switch {
+	case item.Cancelled:
+		trigger.State = pipeline.TriggerCancelled
+	case item.Executable != nil:
+		trigger.State = pipeline.TriggerStarted
+		trigger.Number = item.Executable.Number
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package kubernetes
+
+import (
+	"log"
+	"strings"
+
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// kinds of resources that can be cloned from the source namespace
+const (
+	KindConfigMap             = "configmaps"
+	KindSecret                = "secrets"
+	KindService               = "services"
+	KindDeployment            = "deployments"
+	KindStatefulSet           = "statefulsets"
+	KindPersistentVolumeClaim = "persistentvolumeclaims"
+)
+
+// ClonePolicy is the policy used to clone a kind of resource from the source namespace
+type ClonePolicy struct {
+	Enabled  bool     `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Selector string   `json:"selector,omitempty" yaml:"selector,omitempty" mapstructure:"selector"`
+	Exclude  []string `json:"exclude,omitempty" yaml:"exclude,omitempty" mapstructure:"exclude"`
+}
+
+// ClonePolicies is the clone policy for each kind, the kinds not present are not cloned
+type ClonePolicies map[string]ClonePolicy
+
+// annotations dropped from the cloned resources, since they refer to the source resource
+var droppedAnnotationPrefixes = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/",
+	"pv.kubernetes.io/",
+	"volume.beta.kubernetes.io/",
+	"volume.kubernetes.io/",
+}
+
+func getClonePolicies(v *viper.Viper) ClonePolicies {
+	policies := ClonePolicies{}
+	err := v.UnmarshalKey("clone", &policies)
+	if err != nil {
+		log.Fatalf("unable to unmarshal clone policies: %v", err)
+	}
+	return policies
+}
+
+func (p ClonePolicy) excluded(name string) bool {
+	for _, excluded := range p.Exclude {
+		if excluded == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p ClonePolicy) listOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: p.Selector}
+}
+
+func clonedObjectMeta(src metav1.ObjectMeta, dstNamespace string) metav1.ObjectMeta {
+	annotations := map[string]string{}
+	for key, value := range src.Annotations {
+		dropped := false
+		for _, prefix := range droppedAnnotationPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				dropped = true
+			}
+		}
+		if !dropped {
+			annotations[key] = value
+		}
+	}
+	return metav1.ObjectMeta{
+		Namespace:   dstNamespace,
+		Name:        src.Name,
+		Labels:      src.Labels,
+		Annotations: annotations,
+	}
+}
+
+// CloneResources will clone the accessory resources from the source namespace following the clone policies.
+// Deployments and statefulsets of the selected projects are not cloned, since they are deployed by their pipelines
+func (k *Client) CloneResources(dstNamespace string, selectedProjects []string) (map[string][]string, error) {
+	selected := map[string]bool{}
+	for _, project := range selectedProjects {
+		selected[project] = true
+	}
+	cloned := map[string][]string{}
+	cloners := map[string]func(ClonePolicy, map[string]bool, string) ([]string, error){
+		KindConfigMap:             k.cloneConfigMaps,
+		KindSecret:                k.cloneSecrets,
+		KindService:               k.cloneServices,
+		KindDeployment:            k.cloneDeployments,
+		KindStatefulSet:           k.cloneStatefulSets,
+		KindPersistentVolumeClaim: k.clonePersistentVolumeClaims,
+	}
+	for kind, policy := range k.clonePolicies {
+		cloner, ok := cloners[kind]
+		if !ok {
+			log.Printf("unknown kind %s in clone policies, skipping", kind)
+			continue
+		}
+		if !policy.Enabled {
+			continue
+		}
+		names, err := cloner(policy, selected, dstNamespace)
+		if err != nil {
+			log.Printf("error while cloning %s in namespace %s: %v", kind, dstNamespace, err)
+			return nil, err
+		}
+		cloned[kind] = names
+	}
+	return cloned, nil
+}
+
+// createIgnoringExisting creates a resource, the resources already present in the destination namespace are kept
+func createIgnoringExisting(kind, name string, create func() error) (bool, error) {
+	err := create()
+	if apierrors.IsAlreadyExists(err) {
+		log.Printf("%s %s already exists, skipping", kind, name)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (k *Client) cloneConfigMaps(policy ClonePolicy, selected map[string]bool, dstNamespace string) ([]string, error) {
+	list, err := k.clientSet.CoreV1().ConfigMaps(k.srcNamespace).List(policy.listOptions())
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, item := range list.Items {
+		if policy.excluded(item.Name) {
+			continue
+		}
+		item.ObjectMeta = clonedObjectMeta(item.ObjectMeta, dstNamespace)
+		created, err := createIgnoringExisting(KindConfigMap, item.Name, func() error {
+			_, err := k.clientSet.CoreV1().ConfigMaps(dstNamespace).Create(&item)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			names = append(names, item.Name)
+		}
+	}
+	return names, nil
+}
+
+func (k *Client) cloneSecrets(policy ClonePolicy, selected map[string]bool, dstNamespace string) ([]string, error) {
+	list, err := k.clientSet.CoreV1().Secrets(k.srcNamespace).List(policy.listOptions())
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, item := range list.Items {
+		// service account tokens are generated in every namespace
+		if policy.excluded(item.Name) || item.Type == v1.SecretTypeServiceAccountToken {
+			continue
+		}
+		item.ObjectMeta = clonedObjectMeta(item.ObjectMeta, dstNamespace)
+		created, err := createIgnoringExisting(KindSecret, item.Name, func() error {
+			_, err := k.clientSet.CoreV1().Secrets(dstNamespace).Create(&item)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			names = append(names, item.Name)
+		}
+	}
+	return names, nil
+}
+
+func (k *Client) cloneServices(policy ClonePolicy, selected map[string]bool, dstNamespace string) ([]string, error) {
+	list, err := k.clientSet.CoreV1().Services(k.srcNamespace).List(policy.listOptions())
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, item := range list.Items {
+		if policy.excluded(item.Name) {
+			continue
+		}
+		item.ObjectMeta = clonedObjectMeta(item.ObjectMeta, dstNamespace)
+		item.Status = v1.ServiceStatus{}
+		// the allocated addresses and ports belong to the source service
+		if item.Spec.ClusterIP != v1.ClusterIPNone {
+			item.Spec.ClusterIP = ""
+		}
+		item.Spec.LoadBalancerIP = ""
+		item.Spec.HealthCheckNodePort = 0
+		for idx := range item.Spec.Ports {
+			item.Spec.Ports[idx].NodePort = 0
+		}
+		created, err := createIgnoringExisting(KindService, item.Name, func() error {
+			_, err := k.clientSet.CoreV1().Services(dstNamespace).Create(&item)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			names = append(names, item.Name)
+		}
+	}
+	return names, nil
+}
+
+func (k *Client) cloneDeployments(policy ClonePolicy, selected map[string]bool, dstNamespace string) ([]string, error) {
+	list, err := k.clientSet.AppsV1().Deployments(k.srcNamespace).List(policy.listOptions())
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, item := range list.Items {
+		if policy.excluded(item.Name) || selected[item.Labels["project"]] {
+			continue
+		}
+		item.ObjectMeta = clonedObjectMeta(item.ObjectMeta, dstNamespace)
+		item.Status = appsv1.DeploymentStatus{}
+		created, err := createIgnoringExisting(KindDeployment, item.Name, func() error {
+			_, err := k.clientSet.AppsV1().Deployments(dstNamespace).Create(&item)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			names = append(names, item.Name)
+		}
+	}
+	return names, nil
+}
+
+func (k *Client) cloneStatefulSets(policy ClonePolicy, selected map[string]bool, dstNamespace string) ([]string, error) {
+	list, err := k.clientSet.AppsV1().StatefulSets(k.srcNamespace).List(policy.listOptions())
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, item := range list.Items {
+		if policy.excluded(item.Name) || selected[item.Labels["project"]] {
+			continue
+		}
+		item.ObjectMeta = clonedObjectMeta(item.ObjectMeta, dstNamespace)
+		item.Status = appsv1.StatefulSetStatus{}
+		created, err := createIgnoringExisting(KindStatefulSet, item.Name, func() error {
+			_, err := k.clientSet.AppsV1().StatefulSets(dstNamespace).Create(&item)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			names = append(names, item.Name)
+		}
+	}
+	return names, nil
+}
+
+func (k *Client) clonePersistentVolumeClaims(policy ClonePolicy, selected map[string]bool, dstNamespace string) ([]string, error) {
+	list, err := k.clientSet.CoreV1().PersistentVolumeClaims(k.srcNamespace).List(policy.listOptions())
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, item := range list.Items {
+		if policy.excluded(item.Name) {
+			continue
+		}
+		item.ObjectMeta = clonedObjectMeta(item.ObjectMeta, dstNamespace)
+		item.Status = v1.PersistentVolumeClaimStatus{}
+		// only the template is cloned, a new volume is provisioned for the claim
+		item.Spec.VolumeName = ""
+		created, err := createIgnoringExisting(KindPersistentVolumeClaim, item.Name, func() error {
+			_, err := k.clientSet.CoreV1().PersistentVolumeClaims(dstNamespace).Create(&item)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			names = append(names, item.Name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,132 @@
+package kubernetes
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lzecca78/one/internal/config"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExpiresAtAnnotation is the namespace annotation holding the expiry timestamp of a not stable universe
+const ExpiresAtAnnotation = "one.lzecca78.github.io/expires-at"
+
+func getTTLs(v *viper.Viper) (time.Duration, time.Duration) {
+	defaultTTL, err := time.ParseDuration(config.CheckAndGetString(v, "DEFAULT_TTL"))
+	if err != nil {
+		log.Fatal("failed converting DEFAULT_TTL to duration:", err)
+	}
+	maxTTL, err := time.ParseDuration(config.CheckAndGetString(v, "MAX_TTL"))
+	if err != nil {
+		log.Fatal("failed converting MAX_TTL to duration:", err)
+	}
+	if defaultTTL > maxTTL {
+		log.Fatalf("DEFAULT_TTL %v is greater than MAX_TTL %v", defaultTTL, maxTTL)
+	}
+	return defaultTTL, maxTTL
+}
+
+// NewExpiry returns the expiry of a universe created now, stable universes never expire
+func (k *Client) NewExpiry(stable bool) *time.Time {
+	if stable {
+		return nil
+	}
+	expiresAt := time.Now().Add(k.defaultTTL).UTC().Truncate(time.Second)
+	return &expiresAt
+}
+
+// extendExpiry postpones the expiry by the given duration, starting from now if already expired, without exceeding the max ttl
+func extendExpiry(now, current time.Time, duration, maxTTL time.Duration) time.Time {
+	if current.Before(now) {
+		current = now
+	}
+	expiresAt := current.Add(duration)
+	if limit := now.Add(maxTTL); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	return expiresAt.UTC().Truncate(time.Second)
+}
+
+// namespaceExpiry returns the expiry of the namespace, nil if the namespace does not expire
+func namespaceExpiry(namespace *v1.Namespace) *time.Time {
+	value, ok := namespace.Annotations[ExpiresAtAnnotation]
+	if !ok {
+		return nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Printf("invalid %s annotation %s in namespace %s: %v", ExpiresAtAnnotation, value, namespace.Name, err)
+		return nil
+	}
+	return &expiresAt
+}
+
+// ExtendExpiry postpones the expiry of the universe by the given duration, the default ttl is used when zero
+func (k *Client) ExtendExpiry(namespace string, duration time.Duration) (*time.Time, error) {
+	if duration < 0 {
+		return nil, errors.Errorf("duration %v must be positive", duration)
+	}
+	if duration == 0 {
+		duration = k.defaultTTL
+	}
+	universe, err := k.GetUniverse(namespace)
+	if err != nil {
+		return nil, err
+	}
+	if universe.Spec.Stable {
+		return nil, errors.Errorf("namespace %s is stable and does not expire", namespace)
+	}
+	namespaces := k.clientSet.CoreV1().Namespaces()
+	ns, err := namespaces.Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	current := now
+	if expiresAt := namespaceExpiry(ns); expiresAt != nil {
+		current = *expiresAt
+	}
+	expiresAt := extendExpiry(now, current, duration, k.maxTTL)
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[ExpiresAtAnnotation] = expiresAt.Format(time.RFC3339)
+	_, err = namespaces.Update(ns)
+	if err != nil {
+		return nil, err
+	}
+	universe.Spec.ExpiresAt = &metav1.Time{Time: expiresAt}
+	err = k.UpdateUniverse(universe)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("extended expiry of namespace %s to %v", namespace, expiresAt)
+	return &expiresAt, nil
+}
+
+// SetStable changes the stable label of the namespace, a stable namespace never expires while the other ones expire at expiresAt
+func (k *Client) SetStable(namespace string, stable bool, expiresAt *time.Time) error {
+	namespaces := k.clientSet.CoreV1().Namespaces()
+	ns, err := namespaces.Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	ns.Labels["stable"] = fmt.Sprintf("%v", stable)
+	if expiresAt == nil {
+		delete(ns.Annotations, ExpiresAtAnnotation)
+	} else {
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[ExpiresAtAnnotation] = expiresAt.Format(time.RFC3339)
+	}
+	_, err = namespaces.Update(ns)
+	return err
+}
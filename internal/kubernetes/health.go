@@ -0,0 +1,321 @@
+package kubernetes
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// overall status of a project, from the best to the worst
+const (
+	HealthHealthy     = "Healthy"
+	HealthUnknown     = "Unknown"
+	HealthProgressing = "Progressing"
+	HealthMissing     = "Missing"
+	HealthDegraded    = "Degraded"
+)
+
+// eventsWindow is how far back the warning events are reported
+const eventsWindow = time.Hour
+
+// maxEvents is the maximum number of warning events reported per project
+const maxEvents = 10
+
+// failingReasons are the waiting reasons of a container that will not recover by itself
+var failingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// UniverseHealth summarizes the workloads of every project of a universe
+type UniverseHealth struct {
+	Namespace string                    `json:"namespace"`
+	Status    string                    `json:"status"`
+	Sleeping  bool                      `json:"sleeping"`
+	Projects  map[string]*ProjectHealth `json:"projects"`
+}
+
+// ProjectHealth summarizes the workloads, the warning events and the last build of a project
+type ProjectHealth struct {
+	Status      string             `json:"status"`
+	Build       string             `json:"build,omitempty"`
+	Deployments []DeploymentHealth `json:"deployments"`
+	Pods        []PodHealth        `json:"pods"`
+	Events      []WarningEvent     `json:"events"`
+	// buildStatus is the health of the last build, any status but Healthy affects the status of the project
+	buildStatus string
+}
+
+// BuildHealth is the result of the last build of a project with its health, classified by the ci provider
+type BuildHealth struct {
+	Result string
+	Status string
+}
+
+// DeploymentHealth describes the replicas of a deployment or a statefulset
+type DeploymentHealth struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Desired   int32  `json:"desired"`
+	Ready     int32  `json:"ready"`
+	Updated   int32  `json:"updated"`
+	Available int32  `json:"available"`
+}
+
+// PodHealth describes the readiness and the restarts of a pod
+type PodHealth struct {
+	Name       string            `json:"name"`
+	Phase      string            `json:"phase"`
+	Ready      bool              `json:"ready"`
+	Restarts   int32             `json:"restarts"`
+	Containers []ContainerHealth `json:"containers"`
+}
+
+// ContainerHealth describes a container of a pod, Reason is set while it is waiting or after it terminated
+type ContainerHealth struct {
+	Name     string `json:"name"`
+	Image    string `json:"image"`
+	Tag      string `json:"tag"`
+	Ready    bool   `json:"ready"`
+	Restarts int32  `json:"restarts"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// WarningEvent is a recent warning event of a workload
+type WarningEvent struct {
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"`
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// imageTag returns the tag or the digest of an image, latest when not given
+func imageTag(image string) string {
+	if idx := strings.LastIndex(image, "@"); idx >= 0 {
+		return image[idx+1:]
+	}
+	idx := strings.LastIndex(image, ":")
+	if idx < 0 || strings.Contains(image[idx:], "/") {
+		return "latest"
+	}
+	return image[idx+1:]
+}
+
+func podHealth(pod v1.Pod) PodHealth {
+	health := PodHealth{
+		Name:       pod.Name,
+		Phase:      string(pod.Status.Phase),
+		Containers: []ContainerHealth{},
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			health.Ready = condition.Status == v1.ConditionTrue
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		container := ContainerHealth{
+			Name:     status.Name,
+			Image:    status.Image,
+			Tag:      imageTag(status.Image),
+			Ready:    status.Ready,
+			Restarts: status.RestartCount,
+		}
+		if status.State.Waiting != nil {
+			container.Reason = status.State.Waiting.Reason
+		} else if status.State.Terminated != nil {
+			container.Reason = status.State.Terminated.Reason
+		}
+		health.Restarts += status.RestartCount
+		health.Containers = append(health.Containers, container)
+	}
+	return health
+}
+
+// projectStatus returns the worst status among the workloads and the last build of the project
+func projectStatus(project *ProjectHealth) string {
+	if project.buildStatus == HealthDegraded {
+		return HealthDegraded
+	}
+	for _, pod := range project.Pods {
+		if pod.Phase == string(v1.PodFailed) {
+			return HealthDegraded
+		}
+		for _, container := range pod.Containers {
+			if failingReasons[container.Reason] {
+				return HealthDegraded
+			}
+		}
+	}
+	if len(project.Deployments) == 0 || project.buildStatus == HealthMissing {
+		return HealthMissing
+	}
+	if project.buildStatus == HealthProgressing {
+		return HealthProgressing
+	}
+	for _, deployment := range project.Deployments {
+		if deployment.Ready < deployment.Desired || deployment.Updated < deployment.Desired {
+			return HealthProgressing
+		}
+	}
+	if project.buildStatus == HealthUnknown {
+		return HealthUnknown
+	}
+	return HealthHealthy
+}
+
+// worstStatus returns the status of the universe, the worst among its projects
+func worstStatus(projects map[string]*ProjectHealth) string {
+	rank := map[string]int{HealthHealthy: 0, HealthUnknown: 1, HealthProgressing: 2, HealthMissing: 3, HealthDegraded: 4}
+	status := HealthHealthy
+	for _, project := range projects {
+		if rank[project.Status] > rank[status] {
+			status = project.Status
+		}
+	}
+	return status
+}
+
+// GetHealth summarizes the workloads of the projects of a namespace, grouped by their project label.
+// builds are the last build of each project, missing ones are not considered
+func (k *Client) GetHealth(namespace string, projects []string, builds map[string]BuildHealth) (*UniverseHealth, error) {
+	health := &UniverseHealth{
+		Namespace: namespace,
+		Projects:  map[string]*ProjectHealth{},
+	}
+	project := func(name string) *ProjectHealth {
+		current, ok := health.Projects[name]
+		if !ok {
+			current = &ProjectHealth{
+				Build:       builds[name].Result,
+				buildStatus: builds[name].Status,
+				Deployments: []DeploymentHealth{},
+				Pods:        []PodHealth{},
+				Events:      []WarningEvent{},
+			}
+			health.Projects[name] = current
+		}
+		return current
+	}
+	for _, name := range projects {
+		project(name)
+	}
+	// the project of each workload, used to assign the events
+	owners := map[string]string{}
+
+	ns, err := k.clientSet.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	health.Sleeping = ns.Labels[SleepingLabel] == "true"
+
+	deployments, err := k.clientSet.AppsV1().Deployments(namespace).List(metav1.ListOptions{LabelSelector: "project"})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range deployments.Items {
+		desired := int32(1)
+		if item.Spec.Replicas != nil {
+			desired = *item.Spec.Replicas
+		}
+		current := project(item.Labels["project"])
+		current.Deployments = append(current.Deployments, DeploymentHealth{
+			Kind:      "Deployment",
+			Name:      item.Name,
+			Desired:   desired,
+			Ready:     item.Status.ReadyReplicas,
+			Updated:   item.Status.UpdatedReplicas,
+			Available: item.Status.AvailableReplicas,
+		})
+		owners["Deployment/"+item.Name] = item.Labels["project"]
+	}
+	statefulSets, err := k.clientSet.AppsV1().StatefulSets(namespace).List(metav1.ListOptions{LabelSelector: "project"})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range statefulSets.Items {
+		desired := int32(1)
+		if item.Spec.Replicas != nil {
+			desired = *item.Spec.Replicas
+		}
+		current := project(item.Labels["project"])
+		current.Deployments = append(current.Deployments, DeploymentHealth{
+			Kind:      "StatefulSet",
+			Name:      item.Name,
+			Desired:   desired,
+			Ready:     item.Status.ReadyReplicas,
+			Updated:   item.Status.UpdatedReplicas,
+			Available: item.Status.ReadyReplicas,
+		})
+		owners["StatefulSet/"+item.Name] = item.Labels["project"]
+	}
+	pods, err := k.clientSet.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: "project"})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range pods.Items {
+		current := project(item.Labels["project"])
+		current.Pods = append(current.Pods, podHealth(item))
+		owners["Pod/"+item.Name] = item.Labels["project"]
+		for _, owner := range item.OwnerReferences {
+			owners[owner.Kind+"/"+owner.Name] = item.Labels["project"]
+		}
+	}
+
+	events, err := k.clientSet.CoreV1().Events(namespace).List(metav1.ListOptions{FieldSelector: "type=" + v1.EventTypeWarning})
+	if err != nil {
+		return nil, err
+	}
+	// the most recent events first
+	sort.Slice(events.Items, func(i, j int) bool {
+		return eventTime(events.Items[i]).After(eventTime(events.Items[j]))
+	})
+	since := time.Now().Add(-eventsWindow)
+	for _, item := range events.Items {
+		if eventTime(item).Before(since) {
+			break
+		}
+		name, ok := owners[item.InvolvedObject.Kind+"/"+item.InvolvedObject.Name]
+		if !ok {
+			continue
+		}
+		current := project(name)
+		if len(current.Events) >= maxEvents {
+			continue
+		}
+		current.Events = append(current.Events, WarningEvent{
+			Kind:     item.InvolvedObject.Kind,
+			Name:     item.InvolvedObject.Name,
+			Reason:   item.Reason,
+			Message:  item.Message,
+			Count:    item.Count,
+			LastSeen: eventTime(item),
+		})
+	}
+
+	for _, current := range health.Projects {
+		current.Status = projectStatus(current)
+	}
+	health.Status = worstStatus(health.Projects)
+	return health, nil
+}
+
+// eventTime returns the last time the event was seen, the events reported through the events/v1 api
+// have only the EventTime, the oldest ones only the FirstTimestamp
+func eventTime(event v1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.FirstTimestamp.Time
+}
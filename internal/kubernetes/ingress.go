@@ -0,0 +1,277 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/lzecca78/one/internal/utils"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	v1b1 "k8s.io/api/extensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// served ingress api versions
+const (
+	IngressExtensionsV1beta1 = "extensions/v1beta1"
+	IngressNetworkingV1      = "networking.k8s.io/v1"
+)
+
+// ingressV1 is the networking.k8s.io/v1 Ingress, not available in the vendored api
+type ingressV1 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              ingressV1Spec `json:"spec,omitempty"`
+}
+
+type ingressV1Spec struct {
+	IngressClassName *string           `json:"ingressClassName,omitempty"`
+	DefaultBackend   *ingressV1Backend `json:"defaultBackend,omitempty"`
+	TLS              []ingressV1TLS    `json:"tls,omitempty"`
+	Rules            []ingressV1Rule   `json:"rules,omitempty"`
+}
+
+type ingressV1TLS struct {
+	Hosts      []string `json:"hosts,omitempty"`
+	SecretName string   `json:"secretName,omitempty"`
+}
+
+type ingressV1Rule struct {
+	Host string         `json:"host,omitempty"`
+	HTTP *ingressV1HTTP `json:"http,omitempty"`
+}
+
+type ingressV1HTTP struct {
+	Paths []ingressV1Path `json:"paths"`
+}
+
+type ingressV1Path struct {
+	Path     string           `json:"path,omitempty"`
+	PathType *string          `json:"pathType,omitempty"`
+	Backend  ingressV1Backend `json:"backend"`
+}
+
+type ingressV1Backend struct {
+	Service  *ingressV1ServiceBackend      `json:"service,omitempty"`
+	Resource *v1.TypedLocalObjectReference `json:"resource,omitempty"`
+}
+
+type ingressV1ServiceBackend struct {
+	Name string               `json:"name"`
+	Port ingressV1ServicePort `json:"port,omitempty"`
+}
+
+type ingressV1ServicePort struct {
+	Name   string `json:"name,omitempty"`
+	Number int32  `json:"number,omitempty"`
+}
+
+type ingressV1List struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ingressV1 `json:"items"`
+}
+
+// IngressAPIVersion returns the ingress api version served by the cluster, preferring networking.k8s.io/v1
+func (k *Client) IngressAPIVersion() string {
+	k.ingressVersionOnce.Do(func() {
+		k.ingressVersion = IngressExtensionsV1beta1
+		resources, err := k.clientSet.Discovery().ServerResourcesForGroupVersion(IngressNetworkingV1)
+		if err != nil {
+			log.Printf("%s not served, using %s: %v", IngressNetworkingV1, IngressExtensionsV1beta1, err)
+			return
+		}
+		for _, resource := range resources.APIResources {
+			if resource.Name == "ingresses" {
+				k.ingressVersion = IngressNetworkingV1
+				return
+			}
+		}
+	})
+	return k.ingressVersion
+}
+
+// rewriteHost returns the host of the cloned ingress, prefixing the first label of the host with the namespace
+func rewriteHost(namespace, host string) string {
+	if host == "" {
+		return host
+	}
+	splitHost := strings.Split(host, ".")
+	domain := strings.Join(splitHost[1:], ".")
+	return fmt.Sprintf("%s-%s.%s", namespace, splitHost[0], domain)
+}
+
+func rewriteHosts(namespace string, hosts []string) []string {
+	newHosts := []string{}
+	for _, host := range hosts {
+		newHosts = append(newHosts, rewriteHost(namespace, host))
+	}
+	return newHosts
+}
+
+// CloneIngresses will clone ingresses from a source namespace
+func (k *Client) CloneIngresses(dstNamespace string, projects []string) (*CloneIngressResponse, error) {
+	hosts := utils.StatusPerProject{}
+	version := k.IngressAPIVersion()
+	for _, project := range projects {
+		//TODO add JobName in IngressesWithStatus
+		currentIngressWithStatus := &utils.MultistagingSpecs{
+			Ingresses: []string{},
+			JobName:   pipeline.Name(project, dstNamespace),
+		}
+		hosts[project] = currentIngressWithStatus
+		selector := labels.Set{"project": project}.String()
+		var newHosts []string
+		var err error
+		if version == IngressNetworkingV1 {
+			newHosts, err = k.cloneIngressesV1(dstNamespace, selector)
+		} else {
+			newHosts, err = k.cloneIngressesV1beta1(dstNamespace, selector)
+		}
+		if err != nil {
+			log.Printf("error while cloning ingresses for project %s with error %s", project, err)
+			return nil, err
+		}
+		if len(newHosts) > 0 {
+			currentIngressWithStatus.Ingresses = utils.RemoveDuplicatesFromSlice(newHosts)
+		}
+		log.Printf("currentIngressWithStatus is %v", currentIngressWithStatus)
+	}
+	result := &CloneIngressResponse{
+		NamespaceCreated:    dstNamespace,
+		ProjectsWithDetails: hosts,
+	}
+	return result, nil
+}
+
+func (k *Client) cloneIngressesV1beta1(dstNamespace, selector string) ([]string, error) {
+	srcIngresses := k.clientSet.ExtensionsV1beta1().Ingresses(k.srcNamespace)
+	dstIngresses := k.clientSet.ExtensionsV1beta1().Ingresses(dstNamespace)
+	ingressList, err := srcIngresses.List(metav1.ListOptions{
+		LabelSelector: selector,
+		Limit:         100,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting ingresses for %s in namespace %s", selector, k.srcNamespace)
+	}
+	newHosts := []string{}
+	for _, ingress := range ingressList.Items {
+		ingress.ObjectMeta = metav1.ObjectMeta{
+			Namespace:   dstNamespace,
+			Name:        ingress.ObjectMeta.Name,
+			Annotations: ingress.ObjectMeta.Annotations}
+		ingress.Status = v1b1.IngressStatus{}
+		for idx, currentRule := range ingress.Spec.Rules {
+			newHost := rewriteHost(dstNamespace, currentRule.Host)
+			// rules without host match every host, no record is needed
+			if newHost != "" {
+				newHosts = append(newHosts, newHost)
+			}
+			ingress.Spec.Rules[idx].Host = newHost
+		}
+		for idx, tls := range ingress.Spec.TLS {
+			ingress.Spec.TLS[idx].Hosts = rewriteHosts(dstNamespace, tls.Hosts)
+		}
+		_, err := dstIngresses.Create(&ingress)
+		if err != nil {
+			log.Printf("error while creating ingress %s with error %s", ingress.Name, err)
+			log.Println("will try updating")
+			_, err := dstIngresses.Update(&ingress)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return newHosts, nil
+}
+
+func (k *Client) cloneIngressesV1(dstNamespace, selector string) ([]string, error) {
+	restClient := k.clientSet.NetworkingV1().RESTClient()
+	raw, err := restClient.Get().
+		Namespace(k.srcNamespace).
+		Resource("ingresses").
+		Param("labelSelector", selector).
+		Param("limit", "100").
+		DoRaw()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting ingresses for %s in namespace %s", selector, k.srcNamespace)
+	}
+	var ingressList ingressV1List
+	err = json.Unmarshal(raw, &ingressList)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while unmarshaling ingress list")
+	}
+	newHosts := []string{}
+	for _, ingress := range ingressList.Items {
+		ingress.TypeMeta = metav1.TypeMeta{APIVersion: IngressNetworkingV1, Kind: "Ingress"}
+		ingress.ObjectMeta = metav1.ObjectMeta{
+			Namespace:   dstNamespace,
+			Name:        ingress.ObjectMeta.Name,
+			Annotations: ingress.ObjectMeta.Annotations}
+		for idx, currentRule := range ingress.Spec.Rules {
+			newHost := rewriteHost(dstNamespace, currentRule.Host)
+			// rules without host match every host, no record is needed
+			if newHost != "" {
+				newHosts = append(newHosts, newHost)
+			}
+			ingress.Spec.Rules[idx].Host = newHost
+		}
+		for idx, tls := range ingress.Spec.TLS {
+			ingress.Spec.TLS[idx].Hosts = rewriteHosts(dstNamespace, tls.Hosts)
+		}
+		body, err := json.Marshal(ingress)
+		if err != nil {
+			return nil, errors.Wrap(err, "error while converting ingress to json")
+		}
+		_, err = restClient.Post().
+			Namespace(dstNamespace).
+			Resource("ingresses").
+			SetHeader("Content-Type", "application/json").
+			Body(body).
+			DoRaw()
+		if apierrors.IsAlreadyExists(err) {
+			log.Printf("ingress %s already exists, will try updating", ingress.Name)
+			err = k.updateIngressV1(&ingress)
+		}
+		if err != nil {
+			log.Printf("error while creating ingress %s with error %s", ingress.Name, err)
+			return nil, err
+		}
+	}
+	return newHosts, nil
+}
+
+func (k *Client) updateIngressV1(ingress *ingressV1) error {
+	restClient := k.clientSet.NetworkingV1().RESTClient()
+	raw, err := restClient.Get().
+		Namespace(ingress.Namespace).
+		Resource("ingresses").
+		Name(ingress.Name).
+		DoRaw()
+	if err != nil {
+		return err
+	}
+	var current ingressV1
+	err = json.Unmarshal(raw, &current)
+	if err != nil {
+		return errors.Wrap(err, "error while unmarshaling ingress")
+	}
+	ingress.ResourceVersion = current.ResourceVersion
+	body, err := json.Marshal(ingress)
+	if err != nil {
+		return errors.Wrap(err, "error while converting ingress to json")
+	}
+	_, err = restClient.Put().
+		Namespace(ingress.Namespace).
+		Resource("ingresses").
+		Name(ingress.Name).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		DoRaw()
+	return err
+}
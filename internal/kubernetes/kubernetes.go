@@ -1,28 +1,24 @@
 package kubernetes
 
 import (
-	"crypto/sha512"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/lzecca78/one/internal/config"
-	"github.com/lzecca78/one/internal/jenkins"
+	"github.com/lzecca78/one/internal/git"
+	"github.com/lzecca78/one/internal/pipeline"
 	"github.com/lzecca78/one/internal/utils"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
-	batchV1 "k8s.io/api/batch/v1"
-	v1BatchB1 "k8s.io/api/batch/v1beta1"
 	v1 "k8s.io/api/core/v1"
-	v1b1 "k8s.io/api/extensions/v1beta1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -40,8 +36,13 @@ type Client struct {
 	namespaceValidator      func(string) bool
 	maxUniverseNumber       int
 	maxStableUniverseNumber int
-	seppukuSecret           string
-	url                     string
+	clonePolicies           ClonePolicies
+	profiles                map[string]Profile
+	defaultProfile          string
+	defaultTTL              time.Duration
+	maxTTL                  time.Duration
+	ingressVersion          string
+	ingressVersionOnce      sync.Once
 }
 
 // DefaultNamespaceValidator is a function that change  the namespace adding a prefix
@@ -77,28 +78,41 @@ func NewKubernetesClient(v *viper.Viper, checkNamespaces ...func(string) bool) *
 	srcNamespace := config.CheckAndGetString(v, "K8S_SRCNAMESPACE")
 	maxNamespace := config.CheckAndGetString(v, "MAX_UNIVERSE")
 	maxStableNamespace := config.CheckAndGetString(v, "MAX_STABLE_UNIVERSE")
-	//TODO get url from ingress one(you need at least the ingress name)
-	myURL := config.CheckAndGetString(v, "MY_URL")
 	maxNumNs, err := strconv.Atoi(maxNamespace)
 	maxNumStableNs, err := strconv.Atoi(maxStableNamespace)
 	if err != nil {
 		log.Fatal("failed converting to int:", err)
 	}
+	profiles, defaultProfile := getProfiles(v)
+	defaultTTL, maxTTL := getTTLs(v)
 	return &Client{
 		clientSet:               clientset,
 		srcNamespace:            srcNamespace,
 		namespaceValidator:      checkNamespace,
 		maxUniverseNumber:       maxNumNs,
 		maxStableUniverseNumber: maxNumStableNs,
-		url:                     myURL,
+		clonePolicies:           getClonePolicies(v),
+		profiles:                profiles,
+		defaultProfile:          defaultProfile,
+		defaultTTL:              defaultTTL,
+		maxTTL:                  maxTTL,
 	}
 }
 
 // MyNameSpace describe the namespace informations needed
 type MyNameSpace struct {
-	Name   string `json:"name" yaml:"name"`
-	Stable bool   `json:"stable" yaml:"stable"`
-	Status string `json:"status" yaml:"status"`
+	Name      string     `json:"name" yaml:"name"`
+	Stable    bool       `json:"stable" yaml:"stable"`
+	Status    string     `json:"status" yaml:"status"`
+	Sleeping  bool       `json:"sleeping" yaml:"sleeping"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Remaining string     `json:"remaining,omitempty" yaml:"remaining,omitempty"`
+	Owner     string     `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Teams     []string   `json:"teams,omitempty" yaml:"teams,omitempty"`
+	Purpose   string     `json:"purpose,omitempty" yaml:"purpose,omitempty"`
+	CreatedAt time.Time  `json:"created_at" yaml:"created_at"`
+	//CommitPerProject is the set of the requested commits, empty for the universes created before it was recorded
+	CommitPerProject git.CommitSpec `json:"commit_per_project,omitempty" yaml:"commit_per_project,omitempty"`
 }
 
 // NamespaceManagedList will list all kubernetes namespace handled by one
@@ -120,15 +134,46 @@ func (k *Client) NamespaceManagedList() (nslist []MyNameSpace, err error) {
 			return nil, err
 		}
 
-		nslist = append(nslist, MyNameSpace{
-			Name:   namespace.ObjectMeta.Name,
-			Stable: stableBool,
-			Status: fmt.Sprintf("%v", namespace.Status.Phase),
-		})
+		myNamespace := MyNameSpace{
+			Name:     namespace.ObjectMeta.Name,
+			Stable:   stableBool,
+			Status:   fmt.Sprintf("%v", namespace.Status.Phase),
+			Sleeping: namespace.Labels[SleepingLabel] == "true",
+			Owner:    namespace.Annotations[OwnerAnnotation],
+			Teams:    namespaceTeams(&namespace),
+			Purpose:  namespace.Annotations[PurposeAnnotation],
+			// the namespace is created together with the universe
+			CreatedAt:        namespace.CreationTimestamp.Time,
+			CommitPerProject: namespaceCommits(&namespace),
+		}
+		if expiresAt := namespaceExpiry(&namespace); expiresAt != nil {
+			remaining := time.Until(*expiresAt).Truncate(time.Second)
+			if remaining < 0 {
+				remaining = 0
+			}
+			myNamespace.ExpiresAt = expiresAt
+			myNamespace.Remaining = remaining.String()
+		}
+		nslist = append(nslist, myNamespace)
 	}
 	return nslist, nil
 }
 
+// WithPending returns the namespaces with the pending ones not created yet
+func WithPending(namespaces, pending []MyNameSpace) []MyNameSpace {
+	listed := map[string]bool{}
+	for _, namespace := range namespaces {
+		listed[namespace.Name] = true
+	}
+	all := append([]MyNameSpace{}, namespaces...)
+	for _, namespace := range pending {
+		if !listed[namespace.Name] {
+			all = append(all, namespace)
+		}
+	}
+	return all
+}
+
 // NsConstraintReqs check the ns constraints
 func (k *Client) NsConstraintReqs() error {
 	maxStableNs := k.maxStableUniverseNumber
@@ -139,8 +184,8 @@ func (k *Client) NsConstraintReqs() error {
 	return nil
 }
 
-// UnderMaxNsLimit  check the ns number limits
-func (k *Client) UnderMaxNsLimit() (bool, error) {
+// UnderMaxNsLimit  check the ns number limits, counting the pending namespaces not created yet
+func (k *Client) UnderMaxNsLimit(pending ...MyNameSpace) (bool, error) {
 	err := k.NsConstraintReqs()
 	if err != nil {
 		log.Printf("constraint of namespaces number limits broken: %s", err)
@@ -150,21 +195,23 @@ func (k *Client) UnderMaxNsLimit() (bool, error) {
 	if err != nil {
 		log.Printf("error while getting list of namespace : %v", err)
 	}
+	myNamespaces = WithPending(myNamespaces, pending)
 	var activeNs int
 	for _, namespace := range myNamespaces {
-		if namespace.Status == "Active" {
+		// sleeping universes do not hold any workload
+		if namespace.Status == "Active" && !namespace.Sleeping {
 			activeNs = activeNs + 1
 		}
 
 	}
 	if activeNs >= k.maxUniverseNumber {
-		return false, errors.Errorf("total number of namespace reached: %v", activeNs)
+		return false, errors.Wrapf(ErrUniverseLimit, "total number of namespace reached: %v", activeNs)
 	}
 	return true, nil
 }
 
-// UnderMaxStableNsLimit check the number of stable ns
-func (k *Client) UnderMaxStableNsLimit() (bool, error) {
+// UnderMaxStableNsLimit check the number of stable ns, counting the pending namespaces not created yet
+func (k *Client) UnderMaxStableNsLimit(pending ...MyNameSpace) (bool, error) {
 	err := k.NsConstraintReqs()
 	if err != nil {
 		log.Printf("constraint of namespaces number limits broken: %s", err)
@@ -174,6 +221,7 @@ func (k *Client) UnderMaxStableNsLimit() (bool, error) {
 	if err != nil {
 		log.Printf("error while getting list of namespace : %v", err)
 	}
+	myNamespaces = WithPending(myNamespaces, pending)
 	var activeStableNs int
 	for _, namespace := range myNamespaces {
 		if namespace.Status == "Active" && namespace.Stable {
@@ -186,12 +234,16 @@ func (k *Client) UnderMaxStableNsLimit() (bool, error) {
 	return true, nil
 }
 
-// CreateNamespace will create a kubernetes namespace adding stable labels
-func (k *Client) CreateNamespace(namespace string, stable bool) error {
+// CreateNamespace will create a kubernetes namespace adding stable labels, the provenance annotations and the expiry annotation, if any
+func (k *Client) CreateNamespace(namespace string, stable bool, expiresAt *time.Time, provenance Provenance) error {
 	if !k.namespaceValidator(namespace) {
 		return errors.Errorf("namespace %s does not satisfy given namespaceValidator function", namespace)
 	}
 	namespaces := k.clientSet.CoreV1().Namespaces()
+	annotations, err := provenance.annotations()
+	if err != nil {
+		return err
+	}
 	namespaceResource := &v1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: namespace,
@@ -199,9 +251,13 @@ func (k *Client) CreateNamespace(namespace string, stable bool) error {
 				"scope":  "multistaging",
 				"stable": fmt.Sprintf("%v", stable),
 			},
+			Annotations: annotations,
 		},
 	}
-	_, err := namespaces.Create(namespaceResource)
+	if expiresAt != nil {
+		namespaceResource.Annotations[ExpiresAtAnnotation] = expiresAt.Format(time.RFC3339)
+	}
+	_, err = namespaces.Create(namespaceResource)
 	if err != nil {
 		log.Printf("error creating namespace %s: %v\n", namespace, err)
 		_, err := namespaces.Update(namespaceResource)
@@ -233,93 +289,14 @@ func (k *Client) DeleteNamespace(namespace string) error {
 	return k.clientSet.CoreV1().Namespaces().Delete(namespace, nil)
 }
 
-//CloneIngresses will clone ingresses from a source namespace
-func (k *Client) CloneIngresses(dstNamespace string, projects []string) (*CloneIngressResponse, error) {
-	srcIngresses := k.clientSet.ExtensionsV1beta1().Ingresses(k.srcNamespace)
-	dstIngresses := k.clientSet.ExtensionsV1beta1().Ingresses(dstNamespace)
-	hosts := utils.StatusPerProject{}
-	for _, project := range projects {
-		currentIngressWithStatus, ok := hosts[project]
-		if !ok {
-			//TODO add JobName in IngressesWithStatus
-			currentIngressWithStatus = &utils.MultistagingSpecs{}
-			hosts[project] = currentIngressWithStatus
-		}
-		currentIngressWithStatus.Ingresses = []string{}
-		newJobName := jenkins.GetNewJobName(project, dstNamespace)
-		currentIngressWithStatus.JobName = newJobName
-		ingressList, err := srcIngresses.List(metav1.ListOptions{
-			LabelSelector: labels.Set(metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"project": project,
-				},
-			}.MatchLabels).String(),
-			Limit: 100,
-		})
-		if err != nil {
-			log.Fatalf("error getting ingresses for %s in namespace %s", project, k.srcNamespace)
-		}
-		for _, ingress := range ingressList.Items {
-			ingress.ObjectMeta = metav1.ObjectMeta{
-				Namespace:   dstNamespace,
-				Name:        ingress.ObjectMeta.Name,
-				Annotations: ingress.ObjectMeta.Annotations}
-			ingress.Status = v1b1.IngressStatus{}
-			currentIngressWithStatus, ok := hosts[project]
-			if !ok {
-				//TODO add JobName in IngressesWithStatus
-				currentIngressWithStatus = &utils.MultistagingSpecs{}
-				hosts[project] = currentIngressWithStatus
-			}
-			for idx, currentRule := range ingress.Spec.Rules {
-				host := currentRule.Host
-				splitHost := strings.Split(host, ".")
-				domain := strings.Join(splitHost[1:], ".")
-				newHost := fmt.Sprintf("%s-%s.%s", dstNamespace, splitHost[0], domain)
-				log.Printf("currentIngressWithStatus is %v", currentIngressWithStatus)
-				currentIngressWithStatus.Ingresses = append(currentIngressWithStatus.Ingresses, newHost)
-				currentIngressWithStatus.Ingresses = utils.RemoveDuplicatesFromSlice(currentIngressWithStatus.Ingresses)
-				ingress.Spec.Rules[idx].Host = newHost
-			}
-			_, err := dstIngresses.Create(&ingress)
-			if err != nil {
-				log.Printf("error while creating ingress for project %s with error %s", project, err)
-				log.Println("will try updating")
-				_, err := dstIngresses.Update(&ingress)
-				if err != nil {
-					return nil, err
-				}
-			}
-		}
-	}
-	result := &CloneIngressResponse{
-		NamespaceCreated:    dstNamespace,
-		ProjectsWithDetails: hosts,
-	}
-	return result, nil
-}
-
-//CreateConfigMap is a function that allow to create a configMap in a namespace with a specific content
-func (k *Client) CreateConfigMap(data *CloneIngressResponse, projectJobMap map[string]string, deleteSecret string) error {
-	cfgMap := k.clientSet.CoreV1().ConfigMaps(data.NamespaceCreated)
-	pcfgMap := persistentCfgMap(data, projectJobMap, deleteSecret)
-	_, err := cfgMap.Create(pcfgMap)
-	if err != nil {
-		log.Printf("error while creating configmap %s for project %s with error %v", pcfgMap.ObjectMeta.Name, pcfgMap.ObjectMeta.Namespace, err)
-		log.Println("will try updating")
-		_, err := cfgMap.Update(pcfgMap)
-		if err != nil {
-			return err
-		}
-	}
-	log.Printf("created configmap: %v", *data)
-	return nil
-}
-
-//GetConfigMap is a function that allow to fetch the data in a configmap, with error
+//GetConfigMap is a function that allow to fetch the data in the legacy configmap used as persistence layer before the Universe resource, with error
 func (k *Client) GetConfigMap(namespace, cmName string) (*CloneIngressResponse, map[string]string, error) {
 	cfgMap := k.clientSet.CoreV1().ConfigMaps(namespace)
 	cm, err := cfgMap.Get(cmName, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("error while getting configmap %s: %v", cmName, err)
+		return nil, nil, err
+	}
 
 	data, ok := cm.Data[namespace]
 	if !ok {
@@ -327,7 +304,10 @@ func (k *Client) GetConfigMap(namespace, cmName string) (*CloneIngressResponse,
 		return nil, nil, errors.Errorf("not found %s value in configmap %s: %v ", namespace, cmName, cm)
 	}
 	var datas *CloneIngressResponse
-	json.Unmarshal([]byte(data), &datas)
+	err = json.Unmarshal([]byte(data), &datas)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error while unmarshaling %s value in configmap %s", namespace, cmName)
+	}
 
 	jobs, ok := cm.Data[JobsLabelConfigmap]
 	if !ok {
@@ -335,124 +315,21 @@ func (k *Client) GetConfigMap(namespace, cmName string) (*CloneIngressResponse,
 		return nil, nil, errors.Errorf("not found %s value in configmap %s: %v ", JobsLabelConfigmap, cmName, cm)
 	}
 	var projectJobMap map[string]string
-	json.Unmarshal([]byte(jobs), &projectJobMap)
-
-	return datas, projectJobMap, err
-}
-
-func persistentCfgMap(data *CloneIngressResponse, projectJobMap map[string]string, deleteSecret string) *v1.ConfigMap {
-	content, err := json.Marshal(data)
+	err = json.Unmarshal([]byte(jobs), &projectJobMap)
 	if err != nil {
-		log.Printf("error while converting to json: %s", err)
+		return nil, nil, errors.Wrapf(err, "error while unmarshaling %s value in configmap %s", JobsLabelConfigmap, cmName)
 	}
-	jobMapJSON, err := json.Marshal(projectJobMap)
-	if err != nil {
-		log.Printf("error while converting to json: %s", err)
-	}
-	cm := v1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: data.NamespaceCreated,
-		},
-		Data: map[string]string{
-			data.NamespaceCreated: string(content),
-			JobsLabelConfigmap:    string(jobMapJSON),
-			DeleteSecret:          deleteSecret,
-		},
-	}
-	return &cm
-}
 
-// CreateCronjob will create the seppuku cronjob for self-killing task
-func (k *Client) CreateCronjob(namespace string, stable bool, deleteSecret string) error {
-	cj := &v1BatchB1.CronJob{
-		TypeMeta: metav1.TypeMeta{},
-		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("seppuku-%s", namespace),
-		},
-		Spec: v1BatchB1.CronJobSpec{
-			Schedule: "0 20 * * *",
-			Suspend:  &stable,
-			JobTemplate: v1BatchB1.JobTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: fmt.Sprintf("job-seppuku-%s", namespace),
-				},
-				Spec: *createJob(namespace, k.url, deleteSecret),
-			},
-		},
-	}
-	_, err := k.clientSet.BatchV1beta1().CronJobs(namespace).Create(cj)
-	if err != nil {
-		log.Printf("error while create cronjob in namespace: %s with error: %v", namespace, err)
-	}
-	return err
+	return datas, projectJobMap, nil
 }
 
-func createJob(namespace, url, secret string) *batchV1.JobSpec {
-	deleteURL := fmt.Sprintf("%s/api/stagings/%s?%s=%s", url, namespace, DeleteSecret, secret)
-	job := &batchV1.JobSpec{
-		Template: v1.PodTemplateSpec{
-			ObjectMeta: metav1.ObjectMeta{},
-			Spec: v1.PodSpec{
-				RestartPolicy: v1.RestartPolicyNever,
-				Containers: []v1.Container{
-					v1.Container{
-						Name:  "seppuku",
-						Image: "ubuntu:18.04",
-						Command: []string{
-							"/bin/bash",
-							"-c",
-						},
-						Args: []string{
-							"apt update && apt install -y curl && curl -XDELETE " + deleteURL,
-						},
-						Resources: v1.ResourceRequirements{
-							Limits: v1.ResourceList{
-								v1.ResourceCPU:    resource.MustParse("250m"),
-								v1.ResourceMemory: resource.MustParse("150Mi"),
-							},
-							Requests: v1.ResourceList{
-								v1.ResourceCPU:    resource.MustParse("75m"),
-								v1.ResourceMemory: resource.MustParse("40Mi"),
-							},
-						},
-					},
-				},
-			},
-		},
+// DeleteLegacyCronjob will delete the seppuku cronjob of the universes created before the reaper
+func (k *Client) DeleteLegacyCronjob(namespace string) error {
+	err := k.clientSet.BatchV1beta1().CronJobs(namespace).Delete(fmt.Sprintf("seppuku-%s", namespace), nil)
+	if apierrors.IsNotFound(err) {
+		return nil
 	}
-	return job
-}
-
-//ClientRequest is the atom of the structure of the response of the client once it envelop the choose step
-type projectGitProperties struct {
-	project string
-	sha     string
-	branch  string
-}
-
-//NsNameGen is the function that return the unique namespace
-func NsNameGen(jobs jenkins.JobsParameters) string {
-	cleanList := []projectGitProperties{}
-	for project, commit := range jobs.CommitPerProject {
-		cleanList = append(cleanList, projectGitProperties{
-			project: project,
-			sha:     commit.Sha,
-			branch:  commit.Branch,
-		})
-	}
-	sort.Slice(cleanList, func(i, j int) bool {
-		return strings.Compare(cleanList[i].project, cleanList[j].project) > 0
-	})
-	var concatList string
-	for _, element := range cleanList {
-		concatList = concatList + element.project + element.sha + element.branch
-	}
-	//convert to sha512 and taking first 8 char
-	toByte := []byte(concatList)
-	shaValue := sha512.Sum512(toByte)
-	encodedSha := hex.EncodeToString(shaValue[:])[:8]
-
-	return fmt.Sprintf("ms-%v", encodedSha)
+	return err
 }
 
 // CloneIngressResponse  give the response from cloneIngress
@@ -464,17 +341,16 @@ type CloneIngressResponse struct {
 // jobStatuses :: {jobName: status}
 // jobParams :: { stable? : ... , CommitPerProject : { projectName: { ...Commit ... }}}
 // cloneIngressResp :: {projects_with_details : { projectName: { jobName : ... , CSVRefs : ... } } }
-func EnrichCloneIngressResp(jobStatus jenkins.JobsStatuses, cloneIngressResp *CloneIngressResponse, jobParams *jenkins.JobsParameters) *CloneIngressResponse {
+func EnrichCloneIngressResp(jobStatus pipeline.JobsStatuses, cloneIngressResp *CloneIngressResponse, jobParams *pipeline.JobsParameters) *CloneIngressResponse {
 	for projectName, commitspec := range jobParams.CommitPerProject {
 		projectDetails, ok := cloneIngressResp.ProjectsWithDetails[projectName]
 		if !ok {
-			// assuming cloneIngressResp to contain all projects
-			log.Fatalf("not found %s in %v", projectName, cloneIngressResp)
+			log.Printf("not found %s in %v, skipping", projectName, cloneIngressResp)
+			continue
 		}
 		status, ok := jobStatus[projectDetails.JobName]
 		if !ok {
-			// assuming jobStatus to contain all jobs
-			log.Fatalf("not found %s in %v", projectDetails.JobName, jobStatus)
+			log.Printf("not found status of %s in %v", projectDetails.JobName, jobStatus)
 		}
 		projectDetails.Status = status
 		projectDetails.CVSRefs = commitspec
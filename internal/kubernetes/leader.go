@@ -0,0 +1,106 @@
+package kubernetes
+
+import (
+	"log"
+	"os"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LeaderElector elects a single replica of one through a coordination Lease
+type LeaderElector struct {
+	client        *Client
+	name          string
+	namespace     string
+	identity      string
+	leaseDuration time.Duration
+}
+
+// NewLeaderElector initialize the LeaderElector for the lease with the given name.
+// The lease lives in the namespace of the pod and the identity is the pod name, both read from the downward api
+func (k *Client) NewLeaderElector(name string, leaseDuration time.Duration) *LeaderElector {
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Fatal("failed getting hostname for leader election: ", err)
+		}
+		identity = hostname
+	}
+	return &LeaderElector{
+		client:        k,
+		name:          name,
+		namespace:     ownNamespace(),
+		identity:      identity,
+		leaseDuration: leaseDuration,
+	}
+}
+
+// leaseHeldByOther check if the lease is held by another identity and not yet expired
+func leaseHeldByOther(spec coordinationv1.LeaseSpec, identity string, now time.Time) bool {
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || *spec.HolderIdentity == identity {
+		return false
+	}
+	if spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiresAt := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiresAt)
+}
+
+// IsLeader acquires or renews the lease, it returns true if this replica holds it
+func (e *LeaderElector) IsLeader() bool {
+	leases := e.client.clientSet.CoordinationV1().Leases(e.namespace)
+	now := metav1.NewMicroTime(time.Now())
+	duration := int32(e.leaseDuration.Seconds())
+	lease, err := leases.Get(e.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      e.name,
+				Namespace: e.namespace,
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &e.identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		})
+		if err != nil {
+			log.Printf("error while creating lease %s: %v", e.name, err)
+			return false
+		}
+		log.Printf("%s acquired lease %s", e.identity, e.name)
+		return true
+	}
+	if err != nil {
+		log.Printf("error while getting lease %s: %v", e.name, err)
+		return false
+	}
+	if leaseHeldByOther(lease.Spec, e.identity, now.Time) {
+		return false
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != e.identity {
+		var transitions int32
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.HolderIdentity = &e.identity
+		lease.Spec.AcquireTime = &now
+		lease.Spec.LeaseTransitions = &transitions
+		log.Printf("%s is taking over lease %s", e.identity, e.name)
+	}
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.RenewTime = &now
+	// a conflict means that another replica updated the lease in the meantime
+	_, err = leases.Update(lease)
+	if err != nil {
+		log.Printf("error while updating lease %s: %v", e.name, err)
+		return false
+	}
+	return true
+}
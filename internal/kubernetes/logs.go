@@ -0,0 +1,93 @@
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// maxLogLine is the longest log line streamed, longer ones stop the stream of their container
+const maxLogLine = 1024 * 1024
+
+// LogOptions selects the logs streamed, an empty Container streams all the containers of the pods
+type LogOptions struct {
+	Follow    bool
+	Since     time.Duration
+	Container string
+}
+
+// LogLine is a line logged by a container of a pod
+type LogLine struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Line      string `json:"line"`
+}
+
+// StreamLogs streams the logs of the pods of the project, the channel is closed once all the streams end or the context is done
+func (k *Client) StreamLogs(ctx context.Context, namespace, project string, opts LogOptions) (<-chan LogLine, error) {
+	if !k.namespaceValidator(namespace) {
+		return nil, errors.Errorf("namespace %s does not satisfy given namespaceValidator function", namespace)
+	}
+	pods := k.clientSet.CoreV1().Pods(namespace)
+	podList, err := pods.List(metav1.ListOptions{
+		LabelSelector: labels.Set{"project": project}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(podList.Items) == 0 {
+		return nil, errors.Errorf("no pods found for project %s in namespace %s", project, namespace)
+	}
+	var sinceSeconds *int64
+	if opts.Since > 0 {
+		seconds := int64(opts.Since.Seconds())
+		sinceSeconds = &seconds
+	}
+	lines := make(chan LogLine)
+	var wg sync.WaitGroup
+	for _, pod := range podList.Items {
+		for _, container := range pod.Spec.Containers {
+			if opts.Container != "" && container.Name != opts.Container {
+				continue
+			}
+			stream, err := pods.GetLogs(pod.Name, &v1.PodLogOptions{
+				Container:    container.Name,
+				Follow:       opts.Follow,
+				SinceSeconds: sinceSeconds,
+			}).Context(ctx).Stream()
+			if err != nil {
+				log.Printf("error while streaming logs of container %s of pod %s: %v", container.Name, pod.Name, err)
+				continue
+			}
+			wg.Add(1)
+			go func(pod, container string) {
+				defer wg.Done()
+				defer stream.Close()
+				scanner := bufio.NewScanner(stream)
+				scanner.Buffer(make([]byte, 64*1024), maxLogLine)
+				for scanner.Scan() {
+					select {
+					case lines <- LogLine{Pod: pod, Container: container, Line: scanner.Text()}:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if err := scanner.Err(); err != nil && ctx.Err() == nil {
+					log.Printf("error while reading logs of container %s of pod %s: %v", container, pod, err)
+				}
+			}(pod.Name, container.Name)
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+	return lines, nil
+}
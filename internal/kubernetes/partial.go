@@ -0,0 +1,68 @@
+package kubernetes
+
+import (
+	"fmt"
+	"log"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KindExternalName are the services of a partial universe resolving to the source namespace
+const KindExternalName = "externalnames"
+
+// externalNameService returns the service resolving to the service with the same name in the source namespace
+func externalNameService(src v1.Service, srcNamespace, dstNamespace string) *v1.Service {
+	ports := []v1.ServicePort{}
+	for _, port := range src.Spec.Ports {
+		ports = append(ports, v1.ServicePort{
+			Name:     port.Name,
+			Protocol: port.Protocol,
+			Port:     port.Port,
+		})
+	}
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: dstNamespace,
+			Name:      src.Name,
+			Labels:    src.Labels,
+		},
+		Spec: v1.ServiceSpec{
+			Type:         v1.ServiceTypeExternalName,
+			ExternalName: fmt.Sprintf("%s.%s.svc.cluster.local", src.Name, srcNamespace),
+			Ports:        ports,
+		},
+	}
+}
+
+// RouteToSource creates in a partial universe an ExternalName service for each service of the projects not selected,
+// so they resolve to the shared deployments of the source namespace
+func (k *Client) RouteToSource(dstNamespace string, unselectedProjects []string) ([]string, error) {
+	unselected := map[string]bool{}
+	for _, project := range unselectedProjects {
+		unselected[project] = true
+	}
+	list, err := k.clientSet.CoreV1().Services(k.srcNamespace).List(metav1.ListOptions{LabelSelector: "project"})
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, item := range list.Items {
+		if !unselected[item.Labels["project"]] {
+			continue
+		}
+		service := externalNameService(item, k.srcNamespace, dstNamespace)
+		created, err := createIgnoringExisting(KindExternalName, service.Name, func() error {
+			_, err := k.clientSet.CoreV1().Services(dstNamespace).Create(service)
+			return err
+		})
+		if err != nil {
+			log.Printf("error while creating external name service %s in namespace %s: %v", service.Name, dstNamespace, err)
+			return nil, err
+		}
+		if created {
+			names = append(names, service.Name)
+		}
+	}
+	return names, nil
+}
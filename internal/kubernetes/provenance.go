@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lzecca78/one/internal/git"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// namespace metadata recording who created a universe and from which commits
+const (
+	OwnerAnnotation     = "one.lzecca78.github.io/owner"
+	CreatedAtAnnotation = "one.lzecca78.github.io/created-at"
+	PurposeAnnotation   = "one.lzecca78.github.io/purpose"
+	CommitsAnnotation   = "one.lzecca78.github.io/commits"
+	TeamsAnnotation     = "one.lzecca78.github.io/teams"
+)
+
+// Provenance describes who created a universe, when and why
+type Provenance struct {
+	Owner            string
+	Teams            []string
+	Purpose          string
+	CreatedAt        time.Time
+	CommitPerProject git.CommitSpec
+}
+
+// NamespaceFilter selects the namespaces by owner, repository and branch, empty fields match every namespace
+type NamespaceFilter struct {
+	Owner  string
+	Repo   string
+	Branch string
+}
+
+func (p Provenance) annotations() (map[string]string, error) {
+	commits, err := json.Marshal(p.CommitPerProject)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while converting commits to json")
+	}
+	annotations := map[string]string{
+		CreatedAtAnnotation: p.CreatedAt.UTC().Format(time.RFC3339),
+		CommitsAnnotation:   string(commits),
+	}
+	if p.Owner != "" {
+		annotations[OwnerAnnotation] = p.Owner
+	}
+	if len(p.Teams) > 0 {
+		annotations[TeamsAnnotation] = strings.Join(p.Teams, ",")
+	}
+	if p.Purpose != "" {
+		annotations[PurposeAnnotation] = p.Purpose
+	}
+	return annotations, nil
+}
+
+// namespaceTeams returns the teams of the owner when the namespace was created
+func namespaceTeams(namespace *v1.Namespace) []string {
+	value := namespace.Annotations[TeamsAnnotation]
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// namespaceCommits returns the commits recorded in the namespace, nil for the namespaces created before
+func namespaceCommits(namespace *v1.Namespace) git.CommitSpec {
+	value, ok := namespace.Annotations[CommitsAnnotation]
+	if !ok {
+		return nil
+	}
+	var commits git.CommitSpec
+	err := json.Unmarshal([]byte(value), &commits)
+	if err != nil {
+		log.Printf("invalid %s annotation in namespace %s: %v", CommitsAnnotation, namespace.Name, err)
+		return nil
+	}
+	return commits
+}
+
+// Matches check if the namespace satisfies the filter, the branch is matched against the repo when both are given
+func (f NamespaceFilter) Matches(namespace MyNameSpace) bool {
+	if f.Owner != "" && namespace.Owner != f.Owner {
+		return false
+	}
+	if f.Repo != "" {
+		commit, ok := namespace.CommitPerProject[f.Repo]
+		return ok && (f.Branch == "" || commit.Branch == f.Branch)
+	}
+	if f.Branch != "" {
+		for _, commit := range namespace.CommitPerProject {
+			if commit.Branch == f.Branch {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// SetCommits records in the namespace the commits of the universe, after they are updated
+func (k *Client) SetCommits(namespace string, commits git.CommitSpec) error {
+	value, err := json.Marshal(commits)
+	if err != nil {
+		return errors.Wrap(err, "error while converting commits to json")
+	}
+	namespaces := k.clientSet.CoreV1().Namespaces()
+	ns, err := namespaces.Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[CommitsAnnotation] = string(value)
+	_, err = namespaces.Update(ns)
+	return err
+}
@@ -0,0 +1,173 @@
+package kubernetes
+
+import (
+	"log"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	quotaName      = "universe-quota"
+	limitRangeName = "universe-limits"
+	profileLabel   = "profile"
+)
+
+// Profile is a size profile of a universe, applied as ResourceQuota and LimitRange to its namespace
+type Profile struct {
+	Quota  map[string]string `json:"quota,omitempty" yaml:"quota,omitempty" mapstructure:"quota"`
+	Limits ProfileLimits     `json:"limits,omitempty" yaml:"limits,omitempty" mapstructure:"limits"`
+}
+
+// ProfileLimits are the container limits applied to every container of the universe
+type ProfileLimits struct {
+	Default        map[string]string `json:"default,omitempty" yaml:"default,omitempty" mapstructure:"default"`
+	DefaultRequest map[string]string `json:"defaultRequest,omitempty" yaml:"defaultRequest,omitempty" mapstructure:"defaultRequest"`
+	Max            map[string]string `json:"max,omitempty" yaml:"max,omitempty" mapstructure:"max"`
+	Min            map[string]string `json:"min,omitempty" yaml:"min,omitempty" mapstructure:"min"`
+}
+
+// QuotaUsage describes the resources used by a universe with respect to its profile
+type QuotaUsage struct {
+	Profile string            `json:"profile"`
+	Hard    map[string]string `json:"hard"`
+	Used    map[string]string `json:"used"`
+}
+
+func getProfiles(v *viper.Viper) (map[string]Profile, string) {
+	profiles := map[string]Profile{}
+	err := v.UnmarshalKey("profiles", &profiles)
+	if err != nil {
+		log.Fatalf("unable to unmarshal profiles: %v", err)
+	}
+	for name, profile := range profiles {
+		_, err := profile.resourceQuota()
+		if err != nil {
+			log.Fatalf("invalid quota in profile %s: %v", name, err)
+		}
+		_, err = profile.limitRange()
+		if err != nil {
+			log.Fatalf("invalid limits in profile %s: %v", name, err)
+		}
+	}
+	defaultProfile := v.GetString("defaultProfile")
+	if _, ok := profiles[defaultProfile]; defaultProfile != "" && !ok {
+		log.Fatalf("default profile %s is not defined", defaultProfile)
+	}
+	return profiles, defaultProfile
+}
+
+func resourceList(values map[string]string) (v1.ResourceList, error) {
+	list := v1.ResourceList{}
+	for name, value := range values {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid quantity %s for %s", value, name)
+		}
+		list[v1.ResourceName(name)] = quantity
+	}
+	return list, nil
+}
+
+func (p Profile) resourceQuota() (v1.ResourceQuotaSpec, error) {
+	hard, err := resourceList(p.Quota)
+	return v1.ResourceQuotaSpec{Hard: hard}, err
+}
+
+func (p Profile) limitRange() (v1.LimitRangeSpec, error) {
+	item := v1.LimitRangeItem{Type: v1.LimitTypeContainer}
+	var err error
+	if item.Default, err = resourceList(p.Limits.Default); err != nil {
+		return v1.LimitRangeSpec{}, err
+	}
+	if item.DefaultRequest, err = resourceList(p.Limits.DefaultRequest); err != nil {
+		return v1.LimitRangeSpec{}, err
+	}
+	if item.Max, err = resourceList(p.Limits.Max); err != nil {
+		return v1.LimitRangeSpec{}, err
+	}
+	if item.Min, err = resourceList(p.Limits.Min); err != nil {
+		return v1.LimitRangeSpec{}, err
+	}
+	return v1.LimitRangeSpec{Limits: []v1.LimitRangeItem{item}}, nil
+}
+
+// ResolveProfile returns the profile to apply for the requested one, the default profile is used when none is requested
+func (k *Client) ResolveProfile(requested string) (string, error) {
+	if requested == "" {
+		return k.defaultProfile, nil
+	}
+	if _, ok := k.profiles[requested]; !ok {
+		return "", errors.Errorf("profile %s is not defined", requested)
+	}
+	return requested, nil
+}
+
+// ApplyProfile creates the ResourceQuota and the LimitRange of the profile in the namespace
+func (k *Client) ApplyProfile(namespace, profileName string) error {
+	if profileName == "" {
+		log.Printf("no profile for namespace %s, skipping quota", namespace)
+		return nil
+	}
+	profile, ok := k.profiles[profileName]
+	if !ok {
+		return errors.Errorf("profile %s is not defined", profileName)
+	}
+	meta := metav1.ObjectMeta{
+		Labels: map[string]string{profileLabel: profileName},
+	}
+	quotaSpec, err := profile.resourceQuota()
+	if err != nil {
+		return err
+	}
+	if len(quotaSpec.Hard) > 0 {
+		meta.Name = quotaName
+		_, err = k.clientSet.CoreV1().ResourceQuotas(namespace).Create(&v1.ResourceQuota{ObjectMeta: meta, Spec: quotaSpec})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			log.Printf("error while creating resource quota in namespace %s: %v", namespace, err)
+			return err
+		}
+	}
+	limitSpec, err := profile.limitRange()
+	if err != nil {
+		return err
+	}
+	item := limitSpec.Limits[0]
+	if len(item.Default)+len(item.DefaultRequest)+len(item.Max)+len(item.Min) == 0 {
+		return nil
+	}
+	meta.Name = limitRangeName
+	_, err = k.clientSet.CoreV1().LimitRanges(namespace).Create(&v1.LimitRange{ObjectMeta: meta, Spec: limitSpec})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		log.Printf("error while creating limit range in namespace %s: %v", namespace, err)
+		return err
+	}
+	return nil
+}
+
+// GetQuotaUsage returns the usage of the ResourceQuota of the namespace, nil if the namespace has no quota
+func (k *Client) GetQuotaUsage(namespace string) (*QuotaUsage, error) {
+	quota, err := k.clientSet.CoreV1().ResourceQuotas(namespace).Get(quotaName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	usage := &QuotaUsage{
+		Profile: quota.Labels[profileLabel],
+		Hard:    map[string]string{},
+		Used:    map[string]string{},
+	}
+	for name, quantity := range quota.Status.Hard {
+		usage.Hard[string(name)] = quantity.String()
+	}
+	for name, quantity := range quota.Status.Used {
+		usage.Used[string(name)] = quantity.String()
+	}
+	return usage, nil
+}
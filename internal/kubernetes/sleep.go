@@ -0,0 +1,171 @@
+package kubernetes
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ReplicasAnnotation holds the replicas of a workload before the universe was put to sleep
+	ReplicasAnnotation = "one.lzecca78.github.io/replicas"
+	// SleepingLabel is the namespace label set while the universe sleeps
+	SleepingLabel = "sleeping"
+)
+
+// ErrUniverseLimit is returned when the maximum number of awake universes is reached
+var ErrUniverseLimit = errors.New("maximum number of universes reached")
+
+// sleepReplicas returns the annotations of a workload put to sleep and true if it has to be scaled to zero
+func sleepReplicas(annotations map[string]string, replicas *int32) (map[string]string, bool) {
+	current := int32(1)
+	if replicas != nil {
+		current = *replicas
+	}
+	if current == 0 {
+		return annotations, false
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ReplicasAnnotation] = strconv.Itoa(int(current))
+	return annotations, true
+}
+
+// wakeReplicas returns the replicas recorded before the sleep and true if the workload has to be scaled back
+func wakeReplicas(annotations map[string]string) (int32, bool, error) {
+	value, ok := annotations[ReplicasAnnotation]
+	if !ok {
+		return 0, false, nil
+	}
+	replicas, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "invalid %s annotation %s", ReplicasAnnotation, value)
+	}
+	delete(annotations, ReplicasAnnotation)
+	return int32(replicas), true, nil
+}
+
+// Sleep scales every deployment and statefulset of the namespace to zero, recording their replicas
+func (k *Client) Sleep(namespace string) error {
+	if !k.namespaceValidator(namespace) {
+		return errors.Errorf("namespace %s does not satisfy given namespaceValidator function", namespace)
+	}
+	deployments := k.clientSet.AppsV1().Deployments(namespace)
+	deploymentList, err := deployments.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	zero := int32(0)
+	for _, item := range deploymentList.Items {
+		var scale bool
+		item.Annotations, scale = sleepReplicas(item.Annotations, item.Spec.Replicas)
+		if !scale {
+			continue
+		}
+		item.Spec.Replicas = &zero
+		_, err := deployments.Update(&item)
+		if err != nil {
+			log.Printf("error while scaling deployment %s in namespace %s: %v", item.Name, namespace, err)
+			return err
+		}
+	}
+	statefulSets := k.clientSet.AppsV1().StatefulSets(namespace)
+	statefulSetList, err := statefulSets.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, item := range statefulSetList.Items {
+		var scale bool
+		item.Annotations, scale = sleepReplicas(item.Annotations, item.Spec.Replicas)
+		if !scale {
+			continue
+		}
+		item.Spec.Replicas = &zero
+		_, err := statefulSets.Update(&item)
+		if err != nil {
+			log.Printf("error while scaling statefulset %s in namespace %s: %v", item.Name, namespace, err)
+			return err
+		}
+	}
+	log.Printf("namespace %s is sleeping", namespace)
+	return k.setSleepingLabel(namespace, true)
+}
+
+// Wake scales every deployment and statefulset of the namespace back to the replicas recorded by Sleep
+func (k *Client) Wake(namespace string) error {
+	if !k.namespaceValidator(namespace) {
+		return errors.Errorf("namespace %s does not satisfy given namespaceValidator function", namespace)
+	}
+	deployments := k.clientSet.AppsV1().Deployments(namespace)
+	deploymentList, err := deployments.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, item := range deploymentList.Items {
+		replicas, scale, err := wakeReplicas(item.Annotations)
+		if err != nil {
+			return errors.Wrapf(err, "deployment %s", item.Name)
+		}
+		if !scale {
+			continue
+		}
+		item.Spec.Replicas = &replicas
+		_, err = deployments.Update(&item)
+		if err != nil {
+			log.Printf("error while scaling deployment %s in namespace %s: %v", item.Name, namespace, err)
+			return err
+		}
+	}
+	statefulSets := k.clientSet.AppsV1().StatefulSets(namespace)
+	statefulSetList, err := statefulSets.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, item := range statefulSetList.Items {
+		replicas, scale, err := wakeReplicas(item.Annotations)
+		if err != nil {
+			return errors.Wrapf(err, "statefulset %s", item.Name)
+		}
+		if !scale {
+			continue
+		}
+		item.Spec.Replicas = &replicas
+		_, err = statefulSets.Update(&item)
+		if err != nil {
+			log.Printf("error while scaling statefulset %s in namespace %s: %v", item.Name, namespace, err)
+			return err
+		}
+	}
+	log.Printf("namespace %s is awake", namespace)
+	return k.setSleepingLabel(namespace, false)
+}
+
+// IsSleeping check if the universe of the namespace is sleeping
+func (k *Client) IsSleeping(namespace string) (bool, error) {
+	ns, err := k.clientSet.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	return ns.Labels[SleepingLabel] == "true", nil
+}
+
+func (k *Client) setSleepingLabel(namespace string, sleeping bool) error {
+	namespaces := k.clientSet.CoreV1().Namespaces()
+	ns, err := namespaces.Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if sleeping {
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		ns.Labels[SleepingLabel] = "true"
+	} else {
+		delete(ns.Labels, SleepingLabel)
+	}
+	_, err = namespaces.Update(ns)
+	return err
+}
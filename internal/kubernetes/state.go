@@ -0,0 +1,75 @@
+package kubernetes
+
+import (
+	"fmt"
+	"os"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const stateKey = "state"
+
+// StateStore persists a state of one in a configmap of its own namespace, with optimistic concurrency
+type StateStore struct {
+	client    *Client
+	name      string
+	namespace string
+}
+
+// ownNamespace returns the namespace where one is running, read from the downward api
+func ownNamespace() string {
+	//not using viper to avoid prefix
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		namespace = "default"
+	}
+	return namespace
+}
+
+// NewStateStore initialize the StateStore persisted in the configmap one-<name>
+func (k *Client) NewStateStore(name string) *StateStore {
+	return &StateStore{
+		client:    k,
+		name:      fmt.Sprintf("one-%s", name),
+		namespace: ownNamespace(),
+	}
+}
+
+// Load returns the stored state with its version, both empty if nothing is stored yet
+func (s *StateStore) Load() ([]byte, string, error) {
+	cm, err := s.client.clientSet.CoreV1().ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(cm.Data[stateKey]), cm.ResourceVersion, nil
+}
+
+// Save stores the state if it is still at the loaded version, false is returned if it was changed in the meantime
+func (s *StateStore) Save(data []byte, version string) (bool, error) {
+	configMaps := s.client.clientSet.CoreV1().ConfigMaps(s.namespace)
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            s.name,
+			ResourceVersion: version,
+		},
+		Data: map[string]string{stateKey: string(data)},
+	}
+	var err error
+	if version == "" {
+		_, err = configMaps.Create(cm)
+	} else {
+		_, err = configMaps.Update(cm)
+	}
+	if apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
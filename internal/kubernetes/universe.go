@@ -0,0 +1,405 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lzecca78/one/internal/git"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/lzecca78/one/internal/utils"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// UniverseGroup is the api group of the Universe custom resource
+	UniverseGroup = "one.lzecca78.github.io"
+	// UniverseVersion is the served version of the Universe custom resource
+	UniverseVersion = "v1alpha1"
+	// UniverseKind is the kind of the Universe custom resource
+	UniverseKind     = "Universe"
+	universeResource = "universes"
+	// ConditionReady is the condition set once all the resources of the universe are created
+	ConditionReady = "Ready"
+	// ConditionShaMismatch is true while a project deployed a sha different from the requested one
+	ConditionShaMismatch = "ShaMismatch"
+)
+
+// Universe is the custom resource that persists the state of a multistaging environment
+type Universe struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UniverseSpec   `json:"spec"`
+	Status            UniverseStatus `json:"status,omitempty"`
+}
+
+// UniverseSpec describes the requested universe
+type UniverseSpec struct {
+	Stable           bool           `json:"stable"`
+	CommitPerProject git.CommitSpec `json:"commitPerProject"`
+	Profile          string         `json:"profile,omitempty"`
+	ExpiresAt        *metav1.Time   `json:"expiresAt,omitempty"`
+	Partial          bool           `json:"partial,omitempty"`
+	Owner            string         `json:"owner,omitempty"`
+	Purpose          string         `json:"purpose,omitempty"`
+	// Identity is the name given by a deterministic name template to the current commits, it is updated with them
+	// while the name stays the one of the creation
+	Identity string `json:"identity,omitempty"`
+}
+
+// UniverseStatus describes the resources created for the universe
+type UniverseStatus struct {
+	Projects   utils.StatusPerProject `json:"projects,omitempty"`
+	Jobs       map[string]string      `json:"jobs,omitempty"`
+	Cloned     map[string][]string    `json:"cloned,omitempty"`
+	Conditions []UniverseCondition    `json:"conditions,omitempty"`
+	// Triggers are the last builds started by one for each project, when the ci tracks them
+	Triggers map[string]*pipeline.Trigger `json:"triggers,omitempty"`
+}
+
+// UniverseView is the universe enriched with the live datas of its namespace
+type UniverseView struct {
+	*Universe
+	Quota *QuotaUsage `json:"quota,omitempty"`
+}
+
+// UniverseCondition describes the state of the universe at a certain point
+type UniverseCondition struct {
+	Type               string      `json:"type"`
+	Status             string      `json:"status"`
+	Reason             string      `json:"reason,omitempty"`
+	Message            string      `json:"message,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
+
+// UniverseList is the list of Universe custom resources
+type UniverseList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Universe `json:"items"`
+}
+
+// NewUniverse initialize the Universe for a namespace with the datas collected during its creation
+func NewUniverse(jobsParams *pipeline.JobsParameters, data *CloneIngressResponse, projectJobMap map[string]string) *Universe {
+	universe := &Universe{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: fmt.Sprintf("%s/%s", UniverseGroup, UniverseVersion),
+			Kind:       UniverseKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      data.NamespaceCreated,
+			Namespace: data.NamespaceCreated,
+		},
+		Spec: UniverseSpec{
+			Stable:           jobsParams.Stable,
+			CommitPerProject: jobsParams.CommitPerProject,
+			Profile:          jobsParams.Profile,
+			Partial:          jobsParams.Partial,
+			Purpose:          jobsParams.Purpose,
+		},
+		Status: UniverseStatus{
+			Projects: data.ProjectsWithDetails,
+			Jobs:     projectJobMap,
+		},
+	}
+	universe.SetCondition(ConditionReady, string(v1.ConditionTrue), "Created", "all the resources of the universe have been created")
+	return universe
+}
+
+// JobsParameters returns the parameters of the pipelines of the universe, with its owner and the hosts of each project
+func (u *Universe) JobsParameters() *pipeline.JobsParameters {
+	hosts := map[string][]string{}
+	for project, details := range u.Status.Projects {
+		if details != nil {
+			hosts[project] = details.Ingresses
+		}
+	}
+	return &pipeline.JobsParameters{
+		Stable:           u.Spec.Stable,
+		CommitPerProject: u.Spec.CommitPerProject,
+		Profile:          u.Spec.Profile,
+		Partial:          u.Spec.Partial,
+		Purpose:          u.Spec.Purpose,
+		Owner:            u.Spec.Owner,
+		Hosts:            hosts,
+	}
+}
+
+// Identity returns the name given by a deterministic name template to the current commits of the universe,
+// its name for the universes created before the identity was recorded
+func (u *Universe) Identity() string {
+	if u.Spec.Identity == "" {
+		return u.Name
+	}
+	return u.Spec.Identity
+}
+
+// sameSha check if two shas are the same commit, one of them can be abbreviated
+func sameSha(a, b string) bool {
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// SetDeployedSha records the sha built for the project, flagging it when it is not the requested one.
+// An empty sha clears it, e.g. when another commit is requested. It returns true if the status of the universe changed
+func (u *Universe) SetDeployedSha(project, sha string) bool {
+	details, ok := u.Status.Projects[project]
+	if !ok || details == nil {
+		return false
+	}
+	requested := u.Spec.CommitPerProject[project].Sha
+	mismatch := sha != "" && requested != "" && !sameSha(sha, requested)
+	if details.DeployedSha == sha && details.ShaMismatch == mismatch {
+		return false
+	}
+	details.DeployedSha = sha
+	details.ShaMismatch = mismatch
+	mismatched := []string{}
+	for name, current := range u.Status.Projects {
+		if current != nil && current.ShaMismatch {
+			mismatched = append(mismatched, name)
+		}
+	}
+	if len(mismatched) == 0 {
+		u.SetCondition(ConditionShaMismatch, string(v1.ConditionFalse), "ShaMatch", "every project deployed the requested sha")
+		return true
+	}
+	sort.Strings(mismatched)
+	message := fmt.Sprintf("projects %s deployed a sha different from the requested one", strings.Join(mismatched, ", "))
+	u.SetCondition(ConditionShaMismatch, string(v1.ConditionTrue), "ShaMismatch", message)
+	return true
+}
+
+// SetTrigger stores the trigger of the last build of the project, a nil trigger is not tracked by the ci
+func (u *Universe) SetTrigger(project string, trigger *pipeline.Trigger) {
+	if trigger == nil {
+		return
+	}
+	if u.Status.Triggers == nil {
+		u.Status.Triggers = map[string]*pipeline.Trigger{}
+	}
+	u.Status.Triggers[project] = trigger
+}
+
+// SetCondition adds or updates the condition with the given type
+func (u *Universe) SetCondition(conditionType, status, reason, message string) {
+	condition := UniverseCondition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+	}
+	for idx, current := range u.Status.Conditions {
+		if current.Type == conditionType {
+			if current.Status == status {
+				condition.LastTransitionTime = current.LastTransitionTime
+			}
+			u.Status.Conditions[idx] = condition
+			return
+		}
+	}
+	u.Status.Conditions = append(u.Status.Conditions, condition)
+}
+
+func universePath(namespace string, name ...string) string {
+	path := fmt.Sprintf("/apis/%s/%s", UniverseGroup, UniverseVersion)
+	if namespace != "" {
+		path = fmt.Sprintf("%s/namespaces/%s", path, namespace)
+	}
+	path = fmt.Sprintf("%s/%s", path, universeResource)
+	if len(name) > 0 {
+		path = fmt.Sprintf("%s/%s", path, name[0])
+	}
+	return path
+}
+
+// CreateUniverse will persist the universe in its namespace, updating it if already present
+func (k *Client) CreateUniverse(universe *Universe) error {
+	body, err := json.Marshal(universe)
+	if err != nil {
+		return errors.Wrap(err, "error while converting universe to json")
+	}
+	_, err = k.clientSet.Discovery().RESTClient().Post().
+		AbsPath(universePath(universe.Namespace)).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		DoRaw()
+	if apierrors.IsAlreadyExists(err) {
+		log.Printf("universe %s already exists, will try updating", universe.Name)
+		current, err := k.getUniverse(universe.Namespace)
+		if err != nil {
+			return err
+		}
+		universe.ResourceVersion = current.ResourceVersion
+		return k.UpdateUniverse(universe)
+	}
+	if err != nil {
+		log.Printf("error while creating universe %s: %v", universe.Name, err)
+		return err
+	}
+	log.Printf("created universe: %s", universe.Name)
+	return nil
+}
+
+// UpdateUniverse will replace the universe with the given one, the resourceVersion must be the current one and is refreshed once updated
+func (k *Client) UpdateUniverse(universe *Universe) error {
+	body, err := json.Marshal(universe)
+	if err != nil {
+		return errors.Wrap(err, "error while converting universe to json")
+	}
+	raw, err := k.clientSet.Discovery().RESTClient().Put().
+		AbsPath(universePath(universe.Namespace, universe.Name)).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		DoRaw()
+	if err != nil {
+		log.Printf("error while updating universe %s: %v", universe.Name, err)
+		return err
+	}
+	// the new resourceVersion allows further updates of the same universe
+	var updated Universe
+	err = json.Unmarshal(raw, &updated)
+	if err != nil {
+		return errors.Wrapf(err, "error while unmarshaling universe %s", universe.Name)
+	}
+	universe.ResourceVersion = updated.ResourceVersion
+	return nil
+}
+
+// GetUniverse will fetch the universe of a namespace, migrating it from the legacy configmap if needed
+func (k *Client) GetUniverse(namespace string) (*Universe, error) {
+	universe, err := k.getUniverse(namespace)
+	if apierrors.IsNotFound(err) {
+		log.Printf("universe %s not found, looking for legacy configmap", namespace)
+		return k.migrateConfigMap(namespace)
+	}
+	return universe, err
+}
+
+func (k *Client) getUniverse(namespace string) (*Universe, error) {
+	raw, err := k.clientSet.Discovery().RESTClient().Get().
+		AbsPath(universePath(namespace, namespace)).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var universe Universe
+	err = json.Unmarshal(raw, &universe)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while unmarshaling universe %s", namespace)
+	}
+	return &universe, nil
+}
+
+// ListUniverses will list the universes of all namespaces
+func (k *Client) ListUniverses() ([]Universe, error) {
+	raw, err := k.clientSet.Discovery().RESTClient().Get().
+		AbsPath(universePath("")).
+		DoRaw()
+	if err != nil {
+		log.Printf("error while listing universes: %v", err)
+		return nil, err
+	}
+	var list UniverseList
+	err = json.Unmarshal(raw, &list)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while unmarshaling universe list")
+	}
+	return list.Items, nil
+}
+
+// CreateDeleteSecret stores the secret needed by the cli to delete the namespace
+func (k *Client) CreateDeleteSecret(namespace, deleteSecret string) error {
+	secrets := k.clientSet.CoreV1().Secrets(namespace)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namespace,
+		},
+		StringData: map[string]string{
+			DeleteSecret: deleteSecret,
+		},
+	}
+	_, err := secrets.Create(secret)
+	if err != nil {
+		log.Printf("error while creating secret %s: %v", namespace, err)
+		log.Println("will try updating")
+		_, err = secrets.Update(secret)
+	}
+	return err
+}
+
+// GetDeleteSecret fetches the secret needed by the cli to delete the namespace
+func (k *Client) GetDeleteSecret(namespace string) (string, error) {
+	secret, err := k.clientSet.CoreV1().Secrets(namespace).Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[DeleteSecret]
+	if !ok {
+		return "", errors.Errorf("not found %s value in secret %s", DeleteSecret, namespace)
+	}
+	return string(value), nil
+}
+
+// MigrateConfigMaps creates the universe of every managed namespace still persisted in the legacy configmap and drops its legacy cronjob
+func (k *Client) MigrateConfigMaps() error {
+	namespaces, err := k.NamespaceManagedList()
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		_, err := k.GetUniverse(namespace.Name)
+		if err != nil {
+			log.Printf("unable to migrate namespace %s: %v", namespace.Name, err)
+		}
+		// expired universes are deleted by the reaper
+		err = k.DeleteLegacyCronjob(namespace.Name)
+		if err != nil {
+			log.Printf("unable to delete legacy cronjob of namespace %s: %v", namespace.Name, err)
+		}
+	}
+	return nil
+}
+
+// migrateConfigMap converts the legacy configmap of a namespace to a universe
+func (k *Client) migrateConfigMap(namespace string) (*Universe, error) {
+	data, projectJobMap, err := k.GetConfigMap(namespace, namespace)
+	if err != nil {
+		return nil, err
+	}
+	commits := git.CommitSpec{}
+	for project, details := range data.ProjectsWithDetails {
+		if details.CVSRefs.Branch != "" {
+			commits[project] = details.CVSRefs
+		}
+	}
+	ns, err := k.clientSet.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	jobsParams := &pipeline.JobsParameters{
+		Stable:           ns.Labels["stable"] == "true",
+		CommitPerProject: commits,
+	}
+	universe := NewUniverse(jobsParams, data, projectJobMap)
+	universe.SetCondition(ConditionReady, string(v1.ConditionTrue), "Migrated", "universe migrated from the legacy configmap")
+	err = k.CreateUniverse(universe)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := k.clientSet.CoreV1().ConfigMaps(namespace).Get(namespace, metav1.GetOptions{})
+	if err == nil && cm.Data[DeleteSecret] != "" {
+		err = k.CreateDeleteSecret(namespace, cm.Data[DeleteSecret])
+		if err != nil {
+			log.Printf("unable to migrate delete secret of namespace %s: %v", namespace, err)
+		}
+	}
+	log.Printf("migrated configmap %s to universe", namespace)
+	return universe, nil
+}
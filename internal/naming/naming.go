@@ -0,0 +1,213 @@
+package naming
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/lzecca78/one/internal/config"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	// LegacyPrefix is the prefix of the universes named before the name template
+	LegacyPrefix = "ms-"
+	// maxLength keeps room in the dns label of the hosts, that are prefixed with the name of the universe
+	maxLength     = 40
+	anonymousUser = "anonymous"
+)
+
+var (
+	invalidChars = regexp.MustCompile("[^a-z0-9-]+")
+	dashes       = regexp.MustCompile("-{2,}")
+)
+
+// Values are the datas available to the name template
+type Values struct {
+	User       string
+	JobsParams pipeline.JobsParameters
+}
+
+// Namer generates the names of the universes from a template
+type Namer struct {
+	text          string
+	template      *template.Template
+	prefix        string
+	deterministic bool
+}
+
+// NewNamer initialize the Namer with the NAME_TEMPLATE config
+func NewNamer(v *viper.Viper) *Namer {
+	namer, err := newNamer(config.CheckAndGetString(v, "NAME_TEMPLATE"))
+	if err != nil {
+		log.Fatalf("invalid NAME_TEMPLATE: %v", err)
+	}
+	return namer
+}
+
+func newNamer(text string) (*Namer, error) {
+	tmpl, err := template.New("name").Funcs(funcs(Values{})).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	// the static text before the first action identifies the universes
+	prefix := Sanitize(strings.SplitN(text, "{{", 2)[0])
+	if prefix == "" || !strings.HasPrefix(text, prefix) {
+		return nil, errors.Errorf("template %s must start with a static prefix made of lowercase letters, digits and dashes", text)
+	}
+	return &Namer{text: text, template: tmpl, prefix: prefix, deterministic: calls(tmpl.Tree.Root, "hash")}, nil
+}
+
+// calls check if the function is called anywhere in the parsed template
+func calls(node parse.Node, function string) bool {
+	switch node := node.(type) {
+	case *parse.IdentifierNode:
+		return node.Ident == function
+	case *parse.ListNode:
+		if node == nil {
+			return false
+		}
+		for _, child := range node.Nodes {
+			if calls(child, function) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return calls(node.Pipe, function)
+	case *parse.PipeNode:
+		if node == nil {
+			return false
+		}
+		for _, cmd := range node.Cmds {
+			if calls(cmd, function) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			if calls(arg, function) {
+				return true
+			}
+		}
+	case *parse.IfNode:
+		return calls(node.Pipe, function) || calls(node.List, function) || calls(node.ElseList, function)
+	case *parse.RangeNode:
+		return calls(node.Pipe, function) || calls(node.List, function) || calls(node.ElseList, function)
+	case *parse.WithNode:
+		return calls(node.Pipe, function) || calls(node.List, function) || calls(node.ElseList, function)
+	case *parse.TemplateNode:
+		return calls(node.Pipe, function)
+	}
+	return false
+}
+
+// Hash returns the first 8 chars of the sha512 of the projects with their commits, sorted by project
+func Hash(jobs pipeline.JobsParameters) string {
+	projects := []string{}
+	for project := range jobs.CommitPerProject {
+		projects = append(projects, project)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(projects)))
+	var concatList string
+	for _, project := range projects {
+		commit := jobs.CommitPerProject[project]
+		concatList = concatList + project + commit.Sha + commit.Branch
+	}
+	// a partial universe differs from the full one with the same commits
+	if jobs.Partial {
+		concatList = concatList + "partial"
+	}
+	shaValue := sha512.Sum512([]byte(concatList))
+	return hex.EncodeToString(shaValue[:])[:8]
+}
+
+// firstProject returns the first project in alphabetical order
+func firstProject(jobs pipeline.JobsParameters) string {
+	projects := []string{}
+	for project := range jobs.CommitPerProject {
+		projects = append(projects, project)
+	}
+	if len(projects) == 0 {
+		return ""
+	}
+	sort.Strings(projects)
+	return projects[0]
+}
+
+func funcs(values Values) template.FuncMap {
+	return template.FuncMap{
+		"user": func() string {
+			if values.User == "" {
+				return anonymousUser
+			}
+			return values.User
+		},
+		"project": func() string {
+			return firstProject(values.JobsParams)
+		},
+		"branch": func() string {
+			return values.JobsParams.CommitPerProject[firstProject(values.JobsParams)].Branch
+		},
+		"hash": func() string {
+			return Hash(values.JobsParams)
+		},
+	}
+}
+
+// Sanitize converts the name to a valid dns label
+func Sanitize(name string) string {
+	name = invalidChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = dashes.ReplaceAllString(name, "-")
+	return strings.TrimLeft(name, "-")
+}
+
+func truncate(name string, length int) string {
+	if len(name) > length {
+		name = name[:length]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// Name renders the template with the values, the result is sanitized and truncated
+func (n *Namer) Name(values Values) (string, error) {
+	tmpl, err := n.template.Clone()
+	if err != nil {
+		return "", err
+	}
+	var name bytes.Buffer
+	err = tmpl.Funcs(funcs(values)).Execute(&name, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "error while rendering name template")
+	}
+	return truncate(Sanitize(name.String()), maxLength), nil
+}
+
+// Deterministic check if the template always gives the same name for the same commits,
+// in that case an existing name is the same universe
+func (n *Namer) Deterministic() bool {
+	return n.deterministic
+}
+
+// Unique appends a numeric suffix to the name until it does not exist
+func (n *Namer) Unique(name string, exists func(string) bool) string {
+	candidate := name
+	for idx := 2; exists(candidate); idx++ {
+		suffix := fmt.Sprintf("-%d", idx)
+		candidate = truncate(name, maxLength-len(suffix)) + suffix
+	}
+	return candidate
+}
+
+// Validate check if the namespace is a universe, named by the template or before it
+func (n *Namer) Validate(namespace string) bool {
+	return strings.HasPrefix(namespace, n.prefix) || strings.HasPrefix(namespace, LegacyPrefix)
+}
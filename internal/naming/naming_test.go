@@ -0,0 +1,114 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lzecca78/one/internal/git"
+	"github.com/lzecca78/one/internal/pipeline"
+)
+
+func jobsParams() pipeline.JobsParameters {
+	return pipeline.JobsParameters{
+		CommitPerProject: git.CommitSpec{
+			"repo2": {Branch: "master", Sha: "def"},
+			"repo1": {Branch: "Feature/REF-1415_Beneficiaries", Sha: "abc"},
+		},
+	}
+}
+
+func TestName(t *testing.T) {
+	namer, err := newNamer("ms-{{user}}-{{branch}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name, err := namer.Name(Values{User: "John.Doe", JobsParams: jobsParams()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "ms-john-doe-feature-ref-1415-beneficiari"; name != expected {
+		t.Errorf("expected %s, got %s", expected, name)
+	}
+	if namer.Deterministic() {
+		t.Errorf("template without hash must not be deterministic")
+	}
+}
+
+func TestNameHash(t *testing.T) {
+	namer, err := newNamer("ms-{{hash}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name, err := namer.Name(Values{JobsParams: jobsParams()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != "ms-"+Hash(jobsParams()) || len(name) != 11 {
+		t.Errorf("unexpected name %s", name)
+	}
+	partial := jobsParams()
+	partial.Partial = true
+	if Hash(partial) == Hash(jobsParams()) {
+		t.Errorf("partial universe must have a different hash")
+	}
+	if !namer.Deterministic() {
+		t.Errorf("template with hash must be deterministic")
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	cases := map[string]bool{
+		"hashicorp-{{user}}":                     false,
+		"ms-{{user}}-hash":                       false,
+		"ms-{{if user}}{{hash}}{{end}}":          true,
+		"ms-{{branch}}-{{hash | printf \"%s\"}}": true,
+	}
+	for text, expected := range cases {
+		namer, err := newNamer(text)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", text, err)
+		}
+		if got := namer.Deterministic(); got != expected {
+			t.Errorf("%s: expected deterministic %v, got %v", text, expected, got)
+		}
+	}
+}
+
+func TestUnique(t *testing.T) {
+	namer, err := newNamer("ms-{{user}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	existing := map[string]bool{"ms-john": true, "ms-john-2": true}
+	name := namer.Unique("ms-john", func(name string) bool { return existing[name] })
+	if name != "ms-john-3" {
+		t.Errorf("expected ms-john-3, got %s", name)
+	}
+	long := strings.Repeat("a", maxLength)
+	name = namer.Unique(long, func(name string) bool { return name == long })
+	if len(name) != maxLength || !strings.HasSuffix(name, "-2") {
+		t.Errorf("unexpected name %s", name)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	namer, err := newNamer("uni-{{user}}-{{branch}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for namespace, expected := range map[string]bool{
+		"uni-john-master": true,
+		"ms-1a2b3c4d":     true,
+		"kube-system":     false,
+		"staging":         false,
+	} {
+		if got := namer.Validate(namespace); got != expected {
+			t.Errorf("%s: expected %v, got %v", namespace, expected, got)
+		}
+	}
+	for _, text := range []string{"{{user}}", "MS-{{user}}", "ms-{{unknown}}"} {
+		if _, err := newNamer(text); err == nil {
+			t.Errorf("expected error for template %s", text)
+		}
+	}
+}
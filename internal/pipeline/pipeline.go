@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lzecca78/one/internal/git"
+	"github.com/pkg/errors"
+)
+
+// states of a pipeline not reported by the ci as the result of a build
+const (
+	BuildRunning  = "RUNNING"
+	BuildQueued   = "QUEUED"
+	BuildNotBuilt = "NOT_BUILT"
+)
+
+// states of a build triggered by one
+const (
+	// TriggerQueued is a build waiting in the queue of the ci
+	TriggerQueued = "queued"
+	// TriggerStarted is a build started by the ci, its number is known
+	TriggerStarted = "started"
+	// TriggerCancelled is a build cancelled while in the queue
+	TriggerCancelled = "cancelled"
+	// TriggerLost is a build whose queue item is unknown or expired before it was resolved
+	TriggerLost = "lost"
+)
+
+// ErrNotFound is returned for the pipelines not existing in the ci
+var ErrNotFound = errors.New("pipeline not found")
+
+//JobsParameters is the struct needed by a continuous integration service to configure parametrized jobs
+type JobsParameters struct {
+	Stable           bool
+	CommitPerProject git.CommitSpec
+	Profile          string
+	Partial          bool
+	Purpose          string
+	//Queue waits for a free slot when the maximum number of universes is reached, Priority is honored only for admins
+	Queue    bool
+	Priority int
+	//Owner and Hosts (by repo) are set by one for the templates of the job configuration
+	Owner string              `json:"-"`
+	Hosts map[string][]string `json:"-"`
+}
+
+//BuildContext is the commit to build for a project with the data of its universe
+type BuildContext struct {
+	Commit git.Commit
+	Owner  string
+	Hosts  []string
+}
+
+//BuildContext returns the commit of the repo with the owner and the hosts of the universe
+func (j *JobsParameters) BuildContext(repo string) BuildContext {
+	return BuildContext{
+		Commit: j.CommitPerProject[repo],
+		Owner:  j.Owner,
+		Hosts:  j.Hosts[repo],
+	}
+}
+
+//JobsStatuses is the status of each job, the result of its last build or one of RUNNING, QUEUED and NOT_BUILT
+type JobsStatuses map[string]string
+
+//Name returns the name of the pipeline of the project with the namespace prefix
+func Name(project, namespace string) string {
+	return fmt.Sprintf("%s-%s", namespace, project)
+}
+
+// Build is a build of a pipeline, FinishedAt and Duration are set once it is completed
+type Build struct {
+	Number     int        `json:"number"`
+	Result     string     `json:"result"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Duration   string     `json:"duration,omitempty"`
+	Cause      string     `json:"cause,omitempty"`
+	Sha        string     `json:"sha,omitempty"`
+}
+
+// PipelineBuilds is the status of a pipeline with its last builds, the most recent first.
+// Triggered is the last build triggered by one, when tracked
+type PipelineBuilds struct {
+	Pipeline    string     `json:"pipeline"`
+	Status      string     `json:"status"`
+	QueuedSince *time.Time `json:"queued_since,omitempty"`
+	QueuedWhy   string     `json:"queued_why,omitempty"`
+	Triggered   *Trigger   `json:"triggered,omitempty"`
+	Builds      []Build    `json:"builds"`
+}
+
+// Trigger is a build requested by one, the queue item is resolved to the build number once the ci starts it.
+// FinishedAt is set once the started build is finished
+type Trigger struct {
+	QueueItem   int64      `json:"queueItem,omitempty"`
+	Number      int        `json:"number,omitempty"`
+	State       string     `json:"state"`
+	TriggeredAt time.Time  `json:"triggeredAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Pending check if the trigger has still to be resolved
+func (t *Trigger) Pending() bool {
+	return t.State == TriggerQueued
+}
+
+// Done check if there is nothing left to follow: the build is finished, or it will never start
+func (t *Trigger) Done() bool {
+	if t.State == TriggerStarted {
+		return t.FinishedAt != nil
+	}
+	return !t.Pending()
+}
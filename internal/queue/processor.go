@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"log"
+	"time"
+
+	"github.com/lzecca78/one/internal/config"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/spf13/viper"
+)
+
+// leaseName is the name of the lease used to elect the replica processing the queue
+const leaseName = "one-queue"
+
+// Processor starts the queued requests as soon as there is a free slot
+type Processor struct {
+	queue    *Queue
+	elector  *kubernetes.LeaderElector
+	start    func(Entry) (bool, error)
+	interval time.Duration
+}
+
+// NewProcessor initialize the Processor of the queue.
+// The start function returns false when there is no free slot for the request, an error drops the request from the queue
+func NewProcessor(v *viper.Viper, client *kubernetes.Client, queue *Queue, start func(Entry) (bool, error)) *Processor {
+	interval, err := time.ParseDuration(config.CheckAndGetString(v, "QUEUE_INTERVAL"))
+	if err != nil {
+		log.Fatal("failed converting QUEUE_INTERVAL to duration:", err)
+	}
+	processor := &Processor{
+		queue:    queue,
+		start:    start,
+		interval: interval,
+	}
+	if config.CheckAndGetBool(v, "LEADER_ELECTION") {
+		// the lease outlives a missed renewal, so the leader does not flap between replicas
+		processor.elector = client.NewLeaderElector(leaseName, 2*interval)
+	}
+	return processor
+}
+
+// Run processes the queue every interval, it never returns
+func (p *Processor) Run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.Process()
+		<-ticker.C
+	}
+}
+
+// Process starts the queued requests in order, a request without a free slot does not block the following ones,
+// since the slot could be denied by a quota of its owner. The start function counts the requests already started,
+// so a freed slot is taken by a single request
+func (p *Processor) Process() {
+	if p.elector != nil && !p.elector.IsLeader() {
+		return
+	}
+	entries, err := p.queue.List()
+	if err != nil {
+		log.Printf("unable to list queue: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		started, err := p.start(entry)
+		if err != nil {
+			log.Printf("dropping queued request %s for namespace %s: %v", entry.ID, entry.Namespace, err)
+		}
+		if !started && err == nil {
+			continue
+		}
+		if started {
+			log.Printf("started queued request %s for namespace %s", entry.ID, entry.Namespace)
+		}
+		_, err = p.queue.Cancel(entry.ID)
+		if err != nil {
+			log.Printf("unable to remove request %s from queue: %v", entry.ID, err)
+		}
+	}
+}
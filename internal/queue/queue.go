@@ -0,0 +1,199 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/pkg/errors"
+)
+
+// maxRetries is the number of attempts to update the queue changed concurrently by another replica
+const maxRetries = 5
+
+// ErrNotFound is returned for the requests not in the queue
+var ErrNotFound = errors.New("request not found in queue")
+
+// Store persists the queue, Save must fail with false when the version is not the loaded one
+type Store interface {
+	Load() ([]byte, string, error)
+	Save(data []byte, version string) (bool, error)
+}
+
+// Entry is a request of a universe waiting for a free slot
+type Entry struct {
+	ID         string                  `json:"id"`
+	Namespace  string                  `json:"namespace"`
+	Owner      string                  `json:"owner,omitempty"`
+	Teams      []string                `json:"teams,omitempty"`
+	Priority   int                     `json:"priority"`
+	JobsParams pipeline.JobsParameters `json:"jobs_params"`
+	EnqueuedAt time.Time               `json:"enqueued_at"`
+	Position   int                     `json:"position"`
+}
+
+// Queue is the persisted queue of the requests, ordered by priority and then by arrival
+type Queue struct {
+	store Store
+}
+
+// NewQueue initialize the Queue persisted in the store
+func NewQueue(store Store) *Queue {
+	return &Queue{store: store}
+}
+
+func (q *Queue) load() ([]Entry, string, error) {
+	data, version, err := q.store.Load()
+	if err != nil {
+		return nil, "", err
+	}
+	entries := []Entry{}
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &entries)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error while unmarshaling queue")
+		}
+	}
+	for idx := range entries {
+		entries[idx].Position = idx + 1
+	}
+	return entries, version, nil
+}
+
+// update applies the change to the stored queue, retrying when it was changed concurrently
+func (q *Queue) update(change func([]Entry) ([]Entry, error)) ([]Entry, error) {
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		entries, version, err := q.load()
+		if err != nil {
+			return nil, err
+		}
+		entries, err = change(entries)
+		if err != nil {
+			return nil, err
+		}
+		for idx := range entries {
+			entries[idx].Position = idx + 1
+		}
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return nil, errors.Wrap(err, "error while converting queue to json")
+		}
+		saved, err := q.store.Save(data, version)
+		if err != nil {
+			return nil, err
+		}
+		if saved {
+			return entries, nil
+		}
+		log.Printf("queue changed concurrently, retrying")
+	}
+	return nil, errors.Errorf("unable to update queue after %d attempts", maxRetries)
+}
+
+func find(entries []Entry, id string) int {
+	for idx, entry := range entries {
+		if entry.ID == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+// List returns the queued requests in order
+func (q *Queue) List() ([]Entry, error) {
+	entries, _, err := q.load()
+	return entries, err
+}
+
+// Has check if a request for the namespace is queued
+func (q *Queue) Has(namespace string) bool {
+	entries, _, err := q.load()
+	if err != nil {
+		log.Printf("error while loading queue: %v", err)
+		return false
+	}
+	for _, entry := range entries {
+		if entry.Namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Push queues the request after the ones with the same or higher priority, the queued entry is returned
+func (q *Queue) Push(entry Entry) (*Entry, error) {
+	var id [8]byte
+	_, err := rand.Read(id[:])
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = hex.EncodeToString(id[:])
+	entry.EnqueuedAt = time.Now()
+	entries, err := q.update(func(entries []Entry) ([]Entry, error) {
+		for _, queued := range entries {
+			if queued.Namespace == entry.Namespace {
+				return nil, errors.Errorf("namespace %s is already queued", entry.Namespace)
+			}
+		}
+		position := len(entries)
+		for idx, queued := range entries {
+			if queued.Priority < entry.Priority {
+				position = idx
+				break
+			}
+		}
+		entries = append(entries, Entry{})
+		copy(entries[position+1:], entries[position:])
+		entries[position] = entry
+		return entries, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	queued := entries[find(entries, entry.ID)]
+	return &queued, nil
+}
+
+// Cancel removes the request from the queue, the returned entry is the removed one
+func (q *Queue) Cancel(id string) (*Entry, error) {
+	var removed Entry
+	_, err := q.update(func(entries []Entry) ([]Entry, error) {
+		idx := find(entries, id)
+		if idx < 0 {
+			return nil, ErrNotFound
+		}
+		removed = entries[idx]
+		return append(entries[:idx], entries[idx+1:]...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &removed, nil
+}
+
+// Move places the request at the given position, starting from 1, regardless of its priority
+func (q *Queue) Move(id string, position int) (*Entry, error) {
+	entries, err := q.update(func(entries []Entry) ([]Entry, error) {
+		idx := find(entries, id)
+		if idx < 0 {
+			return nil, ErrNotFound
+		}
+		if position < 1 || position > len(entries) {
+			return nil, errors.Errorf("position %d out of the queue of %d requests", position, len(entries))
+		}
+		entry := entries[idx]
+		entries = append(entries[:idx], entries[idx+1:]...)
+		entries = append(entries, Entry{})
+		copy(entries[position:], entries[position-1:])
+		entries[position-1] = entry
+		return entries, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	moved := entries[find(entries, id)]
+	return &moved, nil
+}
@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// memoryStore is a Store keeping the queue in memory
+type memoryStore struct {
+	data    []byte
+	version int
+}
+
+func (s *memoryStore) Load() ([]byte, string, error) {
+	return s.data, strconv.Itoa(s.version), nil
+}
+
+func (s *memoryStore) Save(data []byte, version string) (bool, error) {
+	if version != strconv.Itoa(s.version) {
+		return false, nil
+	}
+	s.data = data
+	s.version++
+	return true, nil
+}
+
+func namespaces(t *testing.T, q *Queue) []string {
+	entries, err := q.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := []string{}
+	for idx, entry := range entries {
+		if entry.Position != idx+1 {
+			t.Errorf("wrong position %d for %s", entry.Position, entry.Namespace)
+		}
+		names = append(names, entry.Namespace)
+	}
+	return names
+}
+
+func TestPushOrder(t *testing.T) {
+	q := NewQueue(&memoryStore{})
+	for _, entry := range []Entry{
+		{Namespace: "ms-first"},
+		{Namespace: "ms-second"},
+		{Namespace: "ms-urgent", Priority: 10},
+		{Namespace: "ms-third"},
+	} {
+		_, err := q.Push(entry)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	expected := []string{"ms-urgent", "ms-first", "ms-second", "ms-third"}
+	if got := namespaces(t, q); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if _, err := q.Push(Entry{Namespace: "ms-first"}); err == nil {
+		t.Errorf("expected error for namespace already queued")
+	}
+	if !q.Has("ms-second") || q.Has("ms-unknown") {
+		t.Errorf("wrong Has result")
+	}
+}
+
+func TestMoveAndCancel(t *testing.T) {
+	q := NewQueue(&memoryStore{})
+	ids := map[string]string{}
+	for _, namespace := range []string{"ms-a", "ms-b", "ms-c"} {
+		entry, err := q.Push(Entry{Namespace: namespace})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids[namespace] = entry.ID
+	}
+	moved, err := q.Move(ids["ms-c"], 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if moved.Position != 1 {
+		t.Errorf("expected position 1, got %d", moved.Position)
+	}
+	if _, err := q.Move(ids["ms-a"], 4); err == nil {
+		t.Errorf("expected error for position out of the queue")
+	}
+	_, err = q.Cancel(ids["ms-a"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := q.Cancel(ids["ms-a"]); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	expected := []string{"ms-c", "ms-b"}
+	if got := namespaces(t, q); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,113 @@
+package quota
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/spf13/viper"
+)
+
+// kinds of universes counted by a quota
+const (
+	KindUniverses       = "universes"
+	KindStableUniverses = "stable universes"
+)
+
+// Limit is the maximum number of universes, zero means no limit
+type Limit struct {
+	Max       int `json:"max" yaml:"max" mapstructure:"max"`
+	MaxStable int `json:"maxStable" yaml:"maxStable" mapstructure:"maxStable"`
+}
+
+// Quotas are the limits per user and per team, the users without an override get the default user limit
+type Quotas struct {
+	User  Limit            `json:"user" yaml:"user" mapstructure:"user"`
+	Users map[string]Limit `json:"users" yaml:"users" mapstructure:"users"`
+	Teams map[string]Limit `json:"teams" yaml:"teams" mapstructure:"teams"`
+}
+
+// Violation describes the quota exceeded by a request and the universes using it up
+type Violation struct {
+	Quota     string   `json:"quota"`
+	Subject   string   `json:"subject"`
+	Kind      string   `json:"kind"`
+	Limit     int      `json:"limit"`
+	Universes []string `json:"universes"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s quota of %s reached: max %d %s, used by %v", v.Quota, v.Subject, v.Limit, v.Kind, v.Universes)
+}
+
+// GetQuotas reads the quotas section of the config
+func GetQuotas(v *viper.Viper) *Quotas {
+	quotas := &Quotas{}
+	err := v.UnmarshalKey("quotas", quotas)
+	if err != nil {
+		log.Fatalf("unable to unmarshal quotas: %v", err)
+	}
+	return quotas
+}
+
+func (q *Quotas) userLimit(user string) Limit {
+	if limit, ok := q.Users[user]; ok {
+		return limit
+	}
+	return q.User
+}
+
+// check returns the violation of the limit, if any, counting the universes matching owned
+func check(limit Limit, quota, subject string, stable bool, universes []kubernetes.MyNameSpace, owned func(kubernetes.MyNameSpace) bool) *Violation {
+	all := []string{}
+	stables := []string{}
+	for _, universe := range universes {
+		// terminating universes are released soon
+		if universe.Status != "Active" || !owned(universe) {
+			continue
+		}
+		all = append(all, universe.Name)
+		if universe.Stable {
+			stables = append(stables, universe.Name)
+		}
+	}
+	if limit.Max > 0 && len(all) >= limit.Max {
+		return &Violation{Quota: quota, Subject: subject, Kind: KindUniverses, Limit: limit.Max, Universes: all}
+	}
+	if stable && limit.MaxStable > 0 && len(stables) >= limit.MaxStable {
+		return &Violation{Quota: quota, Subject: subject, Kind: KindStableUniverses, Limit: limit.MaxStable, Universes: stables}
+	}
+	return nil
+}
+
+// Check returns the first quota that a new universe of the user would exceed, nil if it can be created.
+// The quotas are not enforced without an authenticated user
+func (q *Quotas) Check(user string, teams []string, stable bool, universes []kubernetes.MyNameSpace) *Violation {
+	if user == "" {
+		return nil
+	}
+	violation := check(q.userLimit(user), "user", user, stable, universes, func(universe kubernetes.MyNameSpace) bool {
+		return universe.Owner == user
+	})
+	if violation != nil {
+		return violation
+	}
+	for _, team := range teams {
+		limit, ok := q.Teams[team]
+		if !ok {
+			continue
+		}
+		violation := check(limit, "team", team, stable, universes, func(universe kubernetes.MyNameSpace) bool {
+			for _, universeTeam := range universe.Teams {
+				if universeTeam == team {
+					return true
+				}
+			}
+			return false
+		})
+		if violation != nil {
+			return violation
+		}
+	}
+	return nil
+}
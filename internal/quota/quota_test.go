@@ -0,0 +1,49 @@
+package quota
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lzecca78/one/internal/kubernetes"
+)
+
+var universes = []kubernetes.MyNameSpace{
+	{Name: "ms-john-1", Owner: "john", Teams: []string{"backend"}, Status: "Active"},
+	{Name: "ms-john-2", Owner: "john", Teams: []string{"backend"}, Status: "Active", Stable: true},
+	{Name: "ms-john-3", Owner: "john", Teams: []string{"backend"}, Status: "Terminating"},
+	{Name: "ms-jane-1", Owner: "jane", Teams: []string{"frontend"}, Status: "Active"},
+}
+
+func TestCheckUser(t *testing.T) {
+	quotas := &Quotas{User: Limit{Max: 2}}
+	violation := quotas.Check("john", nil, false, universes)
+	expected := &Violation{Quota: "user", Subject: "john", Kind: KindUniverses, Limit: 2, Universes: []string{"ms-john-1", "ms-john-2"}}
+	if !reflect.DeepEqual(violation, expected) {
+		t.Errorf("expected %+v, got %+v", expected, violation)
+	}
+	if violation := quotas.Check("jane", nil, false, universes); violation != nil {
+		t.Errorf("unexpected violation %+v", violation)
+	}
+	quotas.Users = map[string]Limit{"john": {Max: 3}}
+	if violation := quotas.Check("john", nil, false, universes); violation != nil {
+		t.Errorf("unexpected violation with override %+v", violation)
+	}
+	if violation := quotas.Check("", nil, false, universes); violation != nil {
+		t.Errorf("unexpected violation without user %+v", violation)
+	}
+}
+
+func TestCheckTeam(t *testing.T) {
+	quotas := &Quotas{Teams: map[string]Limit{"backend": {MaxStable: 1}}}
+	if violation := quotas.Check("bob", []string{"backend"}, false, universes); violation != nil {
+		t.Errorf("unexpected violation for not stable universe %+v", violation)
+	}
+	violation := quotas.Check("bob", []string{"backend"}, true, universes)
+	expected := &Violation{Quota: "team", Subject: "backend", Kind: KindStableUniverses, Limit: 1, Universes: []string{"ms-john-2"}}
+	if !reflect.DeepEqual(violation, expected) {
+		t.Errorf("expected %+v, got %+v", expected, violation)
+	}
+	if violation := quotas.Check("bob", []string{"frontend"}, true, universes); violation != nil {
+		t.Errorf("unexpected violation for team without quota %+v", violation)
+	}
+}
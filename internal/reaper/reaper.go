@@ -0,0 +1,82 @@
+package reaper
+
+import (
+	"log"
+	"time"
+
+	"github.com/lzecca78/one/internal/config"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/spf13/viper"
+)
+
+// leaseName is the name of the lease used to elect the replica running the reaper
+const leaseName = "one-reaper"
+
+// Reaper periodically tears down the expired universes
+type Reaper struct {
+	client   *kubernetes.Client
+	elector  *kubernetes.LeaderElector
+	teardown func(namespace string) error
+	interval time.Duration
+}
+
+// NewReaper initialize the Reaper, the teardown function is the same used by the delete api
+func NewReaper(v *viper.Viper, client *kubernetes.Client, teardown func(namespace string) error) *Reaper {
+	interval, err := time.ParseDuration(config.CheckAndGetString(v, "REAPER_INTERVAL"))
+	if err != nil {
+		log.Fatal("failed converting REAPER_INTERVAL to duration:", err)
+	}
+	reaper := &Reaper{
+		client:   client,
+		teardown: teardown,
+		interval: interval,
+	}
+	if config.CheckAndGetBool(v, "LEADER_ELECTION") {
+		// the lease outlives a missed renewal, so the leader does not flap between replicas
+		reaper.elector = client.NewLeaderElector(leaseName, 2*interval)
+	}
+	return reaper
+}
+
+// expiredNamespaces returns the active namespaces expired before now
+func expiredNamespaces(namespaces []kubernetes.MyNameSpace, now time.Time) []string {
+	expired := []string{}
+	for _, namespace := range namespaces {
+		if namespace.Stable || namespace.Status != "Active" || namespace.ExpiresAt == nil {
+			continue
+		}
+		if namespace.ExpiresAt.Before(now) {
+			expired = append(expired, namespace.Name)
+		}
+	}
+	return expired
+}
+
+// Run reaps the expired universes every interval, it never returns
+func (r *Reaper) Run() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.Reap()
+		<-ticker.C
+	}
+}
+
+// Reap tears down the expired universes once, only the leader acts when leader election is enabled
+func (r *Reaper) Reap() {
+	if r.elector != nil && !r.elector.IsLeader() {
+		return
+	}
+	namespaces, err := r.client.NamespaceManagedList()
+	if err != nil {
+		log.Printf("reaper unable to list namespaces: %v", err)
+		return
+	}
+	for _, namespace := range expiredNamespaces(namespaces, time.Now()) {
+		log.Printf("reaper deleting expired namespace %s", namespace)
+		err := r.teardown(namespace)
+		if err != nil {
+			log.Printf("reaper unable to delete namespace %s: %v", namespace, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package reaper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lzecca78/one/internal/kubernetes"
+)
+
+func TestExpiredNamespaces(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	namespaces := []kubernetes.MyNameSpace{
+		{Name: "ms-expired", Status: "Active", ExpiresAt: &past},
+		{Name: "ms-alive", Status: "Active", ExpiresAt: &future},
+		{Name: "ms-stable", Stable: true, Status: "Active"},
+		{Name: "ms-terminating", Status: "Terminating", ExpiresAt: &past},
+		{Name: "ms-legacy", Status: "Active"},
+	}
+	got := expiredNamespaces(namespaces, now)
+	want := []string{"ms-expired"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,309 @@
+package reconciler
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lzecca78/one/internal/ci"
+	"github.com/lzecca78/one/internal/config"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/route53"
+	"github.com/lzecca78/one/internal/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// leaseName is the name of the lease used to elect the replica running the reconciler
+const leaseName = "one-reconciler"
+
+// Record is a dns record of a universe in one of the hosted zones
+type Record struct {
+	Zone      string `json:"zone"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Report describes the drift between the namespaces, their universes, the dns records and the ci pipeline sets
+type Report struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// NamespacesWithoutUniverse are the managed namespaces whose creation never completed
+	NamespacesWithoutUniverse []string `json:"namespaces_without_universe"`
+	// MissingRecords are the records of the universes not found in their zone
+	MissingRecords []Record `json:"missing_records"`
+	// OrphanRecords are the records of managed hosts not belonging to any namespace
+	OrphanRecords []Record `json:"orphan_records"`
+	// MissingFolders are the universes without their pipeline set (the jenkins folder), they are never repaired
+	MissingFolders []string `json:"missing_folders"`
+	// OrphanFolders are the pipeline sets of managed names not belonging to any namespace
+	OrphanFolders []string `json:"orphan_folders"`
+	Repaired      []string `json:"repaired,omitempty"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+// HasDrift check if anything is out of sync
+func (r *Report) HasDrift() bool {
+	return len(r.NamespacesWithoutUniverse)+len(r.MissingRecords)+len(r.OrphanRecords)+len(r.MissingFolders)+len(r.OrphanFolders) > 0
+}
+
+// snapshot is the state of the systems compared by the reconciler
+type snapshot struct {
+	namespaces []kubernetes.MyNameSpace
+	universes  []kubernetes.Universe
+	records    map[string][]string
+	folders    []string
+}
+
+// owned check if the host of a cloned ingress belongs to a universe. The hosts of the universes are the ones recorded
+// in their status, the prefix of a namespace is matched only for the namespaces without a universe, still under creation:
+// the names can be prefixes of each other (ms-john and ms-john-2)
+func owned(record string, hosts map[string]bool, unrecorded map[string]bool) bool {
+	if hosts[record] {
+		return true
+	}
+	label := strings.Split(record, ".")[0]
+	for namespace := range unrecorded {
+		if strings.HasPrefix(label, namespace+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// compare computes the drift of the snapshot, the namespaces younger than grace may still be under creation
+func compare(s snapshot, now time.Time, grace time.Duration) *Report {
+	report := &Report{
+		CheckedAt:                 now,
+		NamespacesWithoutUniverse: []string{},
+		MissingRecords:            []Record{},
+		OrphanRecords:             []Record{},
+		MissingFolders:            []string{},
+		OrphanFolders:             []string{},
+	}
+	namespaces := map[string]bool{}
+	for _, namespace := range s.namespaces {
+		namespaces[namespace.Name] = true
+	}
+	universes := map[string]bool{}
+	hosts := map[string]bool{}
+	for _, universe := range s.universes {
+		universes[universe.Namespace] = true
+		for _, project := range universe.Status.Projects {
+			for _, record := range project.Ingresses {
+				hosts[record] = true
+			}
+		}
+	}
+	unrecorded := map[string]bool{}
+	for namespace := range namespaces {
+		if !universes[namespace] {
+			unrecorded[namespace] = true
+		}
+	}
+	for _, namespace := range s.namespaces {
+		if universes[namespace.Name] || namespace.Status != "Active" || now.Sub(namespace.CreatedAt) < grace {
+			continue
+		}
+		report.NamespacesWithoutUniverse = append(report.NamespacesWithoutUniverse, namespace.Name)
+	}
+
+	zones := []string{}
+	for zone := range s.records {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		found := map[string]bool{}
+		for _, record := range s.records[zone] {
+			found[record] = true
+			if !owned(record, hosts, unrecorded) {
+				report.OrphanRecords = append(report.OrphanRecords, Record{Zone: zone, Name: record})
+			}
+		}
+		// the universe is persisted once all of its records are created
+		for _, universe := range s.universes {
+			for _, project := range universe.Status.Projects {
+				for _, record := range project.Ingresses {
+					if !found[record] {
+						report.MissingRecords = append(report.MissingRecords, Record{Zone: zone, Name: record, Namespace: universe.Namespace})
+					}
+				}
+			}
+		}
+	}
+
+	folders := map[string]bool{}
+	for _, folder := range s.folders {
+		folders[folder] = true
+		if !namespaces[folder] {
+			report.OrphanFolders = append(report.OrphanFolders, folder)
+		}
+	}
+	for _, universe := range s.universes {
+		if !folders[universe.Namespace] {
+			report.MissingFolders = append(report.MissingFolders, universe.Namespace)
+		}
+	}
+	sort.Strings(report.NamespacesWithoutUniverse)
+	sort.Strings(report.OrphanFolders)
+	sort.Strings(report.MissingFolders)
+	return report
+}
+
+// Reconciler periodically compares the managed namespaces, their universes, the dns records and the ci pipeline sets
+type Reconciler struct {
+	kubernetes *kubernetes.Client
+	r53        *route53.RClient
+	ci         ci.Provider
+	locks      *utils.Locks
+	validate   func(name string) bool
+	elector    *kubernetes.LeaderElector
+	interval   time.Duration
+	grace      time.Duration
+	repair     bool
+}
+
+// NewReconciler initialize the Reconciler, validate selects the names managed by one among namespaces, records and folders
+func NewReconciler(v *viper.Viper, kubernetesClient *kubernetes.Client, r53 *route53.RClient, provider ci.Provider, locks *utils.Locks, validate func(name string) bool) *Reconciler {
+	interval, err := time.ParseDuration(config.CheckAndGetString(v, "RECONCILE_INTERVAL"))
+	if err != nil {
+		log.Fatal("failed converting RECONCILE_INTERVAL to duration:", err)
+	}
+	reconciler := &Reconciler{
+		kubernetes: kubernetesClient,
+		r53:        r53,
+		ci:         provider,
+		locks:      locks,
+		validate:   validate,
+		interval:   interval,
+		// leaves time to the creations in progress to persist their universe
+		grace:  interval,
+		repair: config.CheckAndGetBool(v, "RECONCILE_REPAIR"),
+	}
+	if config.CheckAndGetBool(v, "LEADER_ELECTION") {
+		// the lease outlives a missed renewal, so the leader does not flap between replicas
+		reconciler.elector = kubernetesClient.NewLeaderElector(leaseName, 2*interval)
+	}
+	return reconciler
+}
+
+// Check collects the state of the systems and returns their drift
+func (r *Reconciler) Check() (*Report, error) {
+	namespaces, err := r.kubernetes.NamespaceManagedList()
+	if err != nil {
+		return nil, err
+	}
+	universes, err := r.kubernetes.ListUniverses()
+	if err != nil {
+		return nil, err
+	}
+	records, err := r.r53.ListRecords(func(record string) bool {
+		return r.validate(strings.Split(record, ".")[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+	folders, err := r.ci.PipelineSets()
+	if err != nil {
+		return nil, err
+	}
+	managed := []string{}
+	for _, folder := range folders {
+		if r.validate(folder) {
+			managed = append(managed, folder)
+		}
+	}
+	return compare(snapshot{
+		namespaces: namespaces,
+		universes:  universes,
+		records:    records,
+		folders:    managed,
+	}, time.Now(), r.grace), nil
+}
+
+// Repair deletes the orphans and recreates the missing records of the report, the outcome is recorded in the report
+func (r *Reconciler) Repair(report *Report) {
+	result := func(action string, err error) {
+		if err != nil {
+			log.Printf("reconciler unable to %s: %v", action, err)
+			report.Errors = append(report.Errors, action+": "+err.Error())
+			return
+		}
+		log.Printf("reconciler %s", action)
+		report.Repaired = append(report.Repaired, action)
+	}
+	for _, record := range report.MissingRecords {
+		result("create record "+record.Name+" in zone "+record.Zone, r.r53.CreateZoneRecordSet(record.Zone, record.Name))
+	}
+	for _, record := range report.OrphanRecords {
+		result("delete record "+record.Name+" in zone "+record.Zone, r.r53.DeleteZoneRecordSet(record.Zone, record.Name))
+	}
+	for _, folder := range report.OrphanFolders {
+		result("delete folder "+folder, r.deleteOrphanFolder(folder))
+	}
+	for _, namespace := range report.NamespacesWithoutUniverse {
+		result("delete namespace "+namespace, r.deleteNamespaceWithoutUniverse(namespace))
+	}
+}
+
+// deleteOrphanFolder deletes the folder unless its namespace was created in the meantime
+func (r *Reconciler) deleteOrphanFolder(folder string) error {
+	r.locks.LoadOrStoreLock(folder)
+	defer r.locks.Unlock(folder)
+	_, err := r.kubernetes.GetUniverse(folder)
+	if err == nil {
+		return errors.Errorf("universe %s exists", folder)
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	if r.kubernetes.NamespaceAlreadyCreated(folder) {
+		return errors.Errorf("namespace %s was created in the meantime", folder)
+	}
+	return r.ci.Teardown(folder)
+}
+
+// deleteNamespaceWithoutUniverse deletes the namespace left behind by a failed creation, its records and folder become orphans
+func (r *Reconciler) deleteNamespaceWithoutUniverse(namespace string) error {
+	r.locks.LoadOrStoreLock(namespace)
+	defer r.locks.Unlock(namespace)
+	_, err := r.kubernetes.GetUniverse(namespace)
+	if err == nil {
+		return errors.Errorf("universe %s exists", namespace)
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	return r.kubernetes.DeleteNamespace(namespace)
+}
+
+// Run reconciles every interval, it never returns
+func (r *Reconciler) Run() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.Reconcile()
+		<-ticker.C
+	}
+}
+
+// Reconcile logs the drift once and repairs it when enabled, only the leader acts when leader election is enabled
+func (r *Reconciler) Reconcile() {
+	if r.elector != nil && !r.elector.IsLeader() {
+		return
+	}
+	report, err := r.Check()
+	if err != nil {
+		log.Printf("reconciler unable to check drift: %v", err)
+		return
+	}
+	if !report.HasDrift() {
+		return
+	}
+	log.Printf("reconciler found drift: %+v", *report)
+	if r.repair {
+		r.Repair(report)
+	}
+}
@@ -0,0 +1,62 @@
+package reconciler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/utils"
+)
+
+func universe(namespace string, records ...string) kubernetes.Universe {
+	u := kubernetes.Universe{}
+	u.Name = namespace
+	u.Namespace = namespace
+	u.Status.Projects = utils.StatusPerProject{
+		"repo1": {Ingresses: records},
+	}
+	return u
+}
+
+func TestCompare(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-time.Hour)
+	s := snapshot{
+		namespaces: []kubernetes.MyNameSpace{
+			{Name: "ms-ok", Status: "Active", CreatedAt: old},
+			{Name: "ms-failed", Status: "Active", CreatedAt: old},
+			{Name: "ms-creating", Status: "Active", CreatedAt: now},
+			{Name: "ms-nodns", Status: "Active", CreatedAt: old},
+		},
+		universes: []kubernetes.Universe{
+			universe("ms-ok", "ms-ok-api.example.com"),
+			universe("ms-nodns", "ms-nodns-api.example.com"),
+		},
+		records: map[string][]string{
+			"zone": {"ms-ok-api.example.com", "ms-failed-api.example.com", "ms-gone-api.example.com", "ms-ok-2-api.example.com"},
+		},
+		folders: []string{"ms-ok", "ms-creating", "ms-gone"},
+	}
+	report := compare(s, now, time.Minute)
+	if !reflect.DeepEqual(report.NamespacesWithoutUniverse, []string{"ms-failed"}) {
+		t.Errorf("unexpected namespaces without universe %v", report.NamespacesWithoutUniverse)
+	}
+	missing := []Record{{Zone: "zone", Name: "ms-nodns-api.example.com", Namespace: "ms-nodns"}}
+	if !reflect.DeepEqual(report.MissingRecords, missing) {
+		t.Errorf("expected missing records %v, got %v", missing, report.MissingRecords)
+	}
+	orphans := []Record{{Zone: "zone", Name: "ms-gone-api.example.com"}, {Zone: "zone", Name: "ms-ok-2-api.example.com"}}
+	if !reflect.DeepEqual(report.OrphanRecords, orphans) {
+		t.Errorf("expected orphan records %v, got %v", orphans, report.OrphanRecords)
+	}
+	if !reflect.DeepEqual(report.MissingFolders, []string{"ms-nodns"}) {
+		t.Errorf("unexpected missing folders %v", report.MissingFolders)
+	}
+	if !reflect.DeepEqual(report.OrphanFolders, []string{"ms-gone"}) {
+		t.Errorf("unexpected orphan folders %v", report.OrphanFolders)
+	}
+	if !report.HasDrift() {
+		t.Error("expected drift")
+	}
+}
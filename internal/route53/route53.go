@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/lzecca78/one/internal/config"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/external"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
 
@@ -71,18 +73,91 @@ func (r *RClient) r53Action(action, record string) ([]*route53.ChangeResourceRec
 	var err error
 	var responses []*route53.ChangeResourceRecordSetsResponse
 	for _, recSet := range listRecords {
-		message := fmt.Sprintf("%s record %s in zone %s to target %s", action, record, recSet.ZoneID, recSet.LbCname)
-		input := recordSetInput(message, record, route53.ChangeAction(action), recSet)
-		req := r.R53Client.ChangeResourceRecordSetsRequest(input)
-		resp, err := req.Send(context.TODO())
-		if err != nil {
-			log.Printf("there was en error: %s: %s", resp, err)
+		resp, zoneErr := r.zoneAction(action, record, recSet)
+		if zoneErr != nil {
+			// the other zone is changed anyway, the last error is returned
+			err = zoneErr
 		}
 		responses = append(responses, resp)
 	}
 	return responses, err
 }
 
+func (r *RClient) zoneAction(action, record string, recSet AliasRecord) (*route53.ChangeResourceRecordSetsResponse, error) {
+	message := fmt.Sprintf("%s record %s in zone %s to target %s", action, record, recSet.ZoneID, recSet.LbCname)
+	input := recordSetInput(message, record, route53.ChangeAction(action), recSet)
+	req := r.R53Client.ChangeResourceRecordSetsRequest(input)
+	resp, err := req.Send(context.TODO())
+	if err != nil {
+		log.Printf("there was en error: %s: %s", resp, err)
+		return resp, errors.Wrapf(err, "%s record %s in zone %s", action, record, recSet.ZoneID)
+	}
+	return resp, nil
+}
+
+// Zones returns the ids of the hosted zones where the records are created
+func (r *RClient) Zones() []string {
+	return []string{r.PrivateRecord.ZoneID, r.PublicRecord.ZoneID}
+}
+
+func (r *RClient) aliasRecord(zoneID string) (AliasRecord, error) {
+	for _, recSet := range []AliasRecord{r.PrivateRecord, r.PublicRecord} {
+		if recSet.ZoneID == zoneID {
+			return recSet, nil
+		}
+	}
+	return AliasRecord{}, errors.Errorf("zone %s is not managed", zoneID)
+}
+
+// CreateZoneRecordSet will create a record only in the given zone
+func (r *RClient) CreateZoneRecordSet(zoneID, record string) error {
+	recSet, err := r.aliasRecord(zoneID)
+	if err != nil {
+		return err
+	}
+	_, err = r.zoneAction("UPSERT", record, recSet)
+	return err
+}
+
+// DeleteZoneRecordSet will delete a record only from the given zone
+func (r *RClient) DeleteZoneRecordSet(zoneID, record string) error {
+	recSet, err := r.aliasRecord(zoneID)
+	if err != nil {
+		return err
+	}
+	_, err = r.zoneAction("DELETE", record, recSet)
+	return err
+}
+
+// ListRecords returns the names of the CNAME records of each zone satisfying the filter, without the trailing dot
+func (r *RClient) ListRecords(filter func(record string) bool) (map[string][]string, error) {
+	records := map[string][]string{}
+	for _, zoneID := range r.Zones() {
+		input := &route53.ListResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneID),
+		}
+		req := r.R53Client.ListResourceRecordSetsRequest(input)
+		pages := route53.NewListResourceRecordSetsPaginator(req)
+		names := []string{}
+		for pages.Next(context.TODO()) {
+			for _, recordSet := range pages.CurrentPage().ResourceRecordSets {
+				if recordSet.Type != route53.RRTypeCname || recordSet.Name == nil {
+					continue
+				}
+				name := strings.TrimSuffix(*recordSet.Name, ".")
+				if filter(name) {
+					names = append(names, name)
+				}
+			}
+		}
+		if err := pages.Err(); err != nil {
+			return nil, errors.Wrapf(err, "error while listing records of zone %s", zoneID)
+		}
+		records[zoneID] = names
+	}
+	return records, nil
+}
+
 func recordSetInput(message, record string, action route53.ChangeAction, aliasSet AliasRecord) *route53.ChangeResourceRecordSetsInput {
 	changeResourceInput := &route53.ChangeResourceRecordSetsInput{
 		ChangeBatch: &route53.ChangeBatch{
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin allows only the admins of the config to call the api
+func (router *Router) RequireAdmin(f gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !router.IsAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only admins are allowed"})
+			return
+		}
+		f(c)
+	}
+}
+
+// GetDrift will return the drift between namespaces, universes, dns records and jenkins folders
+func (router *Router) GetDrift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := router.Reconciler.Check()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// RepairDrift will delete the orphans and recreate the missing records, returning the repaired drift
+func (router *Router) RepairDrift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := router.Reconciler.Check()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		router.Reconciler.Repair(report)
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExtendRequest is the body of the api /stagings/:namespace/extend
+type ExtendRequest struct {
+	Duration string `json:"duration"`
+}
+
+// ExtendNamespace will postpone the expiry of the namespace passed as an api field
+func (router *Router) ExtendNamespace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		var request ExtendRequest
+		// the body is optional, the default ttl is used without it
+		if c.Request.ContentLength > 0 {
+			err := c.BindJSON(&request)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		var duration time.Duration
+		if request.Duration != "" {
+			var err error
+			duration, err = time.ParseDuration(request.Duration)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		router.LoadOrStoreLock(namespace)
+		defer router.Unlock(namespace)
+		expiresAt, err := router.KubernetesClient.ExtendExpiry(namespace, duration)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"namespace":  namespace,
+			"expires_at": expiresAt,
+			"remaining":  time.Until(*expiresAt).Truncate(time.Second).String(),
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/pipeline"
+)
+
+// buildHealth classifies the result of a jenkins build as the health of its project, Unknown for the results it does not know
+func buildHealth(result string) kubernetes.BuildHealth {
+	switch result {
+	case "SUCCESS":
+		return kubernetes.BuildHealth{Result: result, Status: kubernetes.HealthHealthy}
+	case "FAILURE", "ABORTED", "UNSTABLE":
+		return kubernetes.BuildHealth{Result: result, Status: kubernetes.HealthDegraded}
+	case pipeline.BuildRunning, pipeline.BuildQueued:
+		return kubernetes.BuildHealth{Result: result, Status: kubernetes.HealthProgressing}
+	case pipeline.BuildNotBuilt:
+		return kubernetes.BuildHealth{Result: result, Status: kubernetes.HealthMissing}
+	}
+	return kubernetes.BuildHealth{Result: result, Status: kubernetes.HealthUnknown}
+}
+
+// NamespaceHealth will return the health of the workloads of each project of the namespace passed as an api field
+func (router *Router) NamespaceHealth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		universe, err := router.KubernetesClient.GetUniverse(namespace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// the workloads are reported even without the builds
+		statuses, err := router.PipelineStatuses(universe)
+		if err != nil {
+			log.Printf("error while getting job status of namespace %s: %v", namespace, err)
+		}
+		projects := []string{}
+		builds := map[string]kubernetes.BuildHealth{}
+		for project, details := range universe.Status.Projects {
+			projects = append(projects, project)
+			if status, ok := statuses[details.JobName]; ok {
+				builds[project] = buildHealth(status)
+			}
+		}
+		health, err := router.KubernetesClient.GetHealth(namespace, projects, builds)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, health)
+	}
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lzecca78/one/internal/ci"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/gin-gonic/gin"
+)
+
+// StreamLogs will stream over server-sent events the logs of the pods of the project passed as an api field.
+// The query parameters are follow (bool), since (duration, e.g. 10m) and container
+func (router *Router) StreamLogs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		opts := kubernetes.LogOptions{Container: c.Query("container")}
+		var err error
+		if follow := c.Query("follow"); follow != "" {
+			opts.Follow, err = strconv.ParseBool(follow)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid follow: " + err.Error()})
+				return
+			}
+		}
+		if since := c.Query("since"); since != "" {
+			opts.Since, err = time.ParseDuration(since)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since: " + err.Error()})
+				return
+			}
+		}
+		// the stream ends when the client goes away
+		ctx := c.Request.Context()
+		lines, err := router.KubernetesClient.StreamLogs(ctx, namespace, c.Param("project"), opts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					c.SSEvent("end", "")
+					return false
+				}
+				c.SSEvent("log", line)
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}
+}
+
+// StreamPipelineLog will stream over server-sent events the console of a build of the pipeline of the repo passed as an api field.
+// The query parameter build selects the build number, the last build is streamed without it
+func (router *Router) StreamPipelineLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		repo := c.Param("repo")
+		streamer, ok := router.CIProvider.(ci.LogStreamer)
+		if !ok {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "the ci provider does not stream the console of the builds"})
+			return
+		}
+		build := c.Query("build")
+		if build != "" {
+			number, err := strconv.Atoi(build)
+			if err != nil || number < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid build %s", build)})
+				return
+			}
+		}
+		universe, err := router.KubernetesClient.GetUniverse(namespace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		pipeline, ok := universe.Status.Jobs[repo]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("project %s not found in namespace %s", repo, namespace)})
+			return
+		}
+		// the stream ends when the client goes away
+		ctx := c.Request.Context()
+		lines, errs := streamer.StreamLog(ctx, pipeline, namespace, build)
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					select {
+					case err := <-errs:
+						c.SSEvent("error", err.Error())
+					default:
+						c.SSEvent("end", "")
+					}
+					return false
+				}
+				c.SSEvent("log", line)
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}
+}
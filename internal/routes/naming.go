@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lzecca78/one/internal/auth"
+	"github.com/lzecca78/one/internal/naming"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/pkg/errors"
+)
+
+// ErrNameLocked is returned when the deterministic name of a universe is locked by a concurrent request
+var ErrNameLocked = errors.New("the universe is being changed by a concurrent request")
+
+// UniverseName returns the name of the universe requested by the current user, with its lock held.
+// The same commits get the same name only with deterministic names, the name of the universe holding them if it was
+// created for other commits and updated, ErrNameLocked is returned while the name is locked.
+// Otherwise a free name is chosen: a name locked by a concurrent creation is taken as well, so the concurrent requests get different names.
+// The lock is not waited for, since the caller holds the admission lock
+func (router *Router) UniverseName(c *gin.Context, jobsParams pipeline.JobsParameters) (string, error) {
+	name, err := router.Namer.Name(naming.Values{User: auth.CurrentUser(c), JobsParams: jobsParams})
+	if err != nil {
+		return "", err
+	}
+	if router.Namer.Deterministic() {
+		owner, renamed, err := router.identityOwner(name)
+		if err != nil {
+			return "", err
+		}
+		if renamed {
+			// the universe named after these commits holds other ones now, the commits get a new universe
+			return router.Namer.Unique(name, func(candidate string) bool {
+				return router.NamespaceTaken(candidate) || !router.TryLock(candidate)
+			}), nil
+		}
+		name = owner
+		if !router.TryLock(name) {
+			return "", ErrNameLocked
+		}
+		return name, nil
+	}
+	return router.Namer.Unique(name, func(candidate string) bool {
+		return router.NamespaceTaken(candidate) || !router.TryLock(candidate)
+	}), nil
+}
+
+// identityOwner returns the name of the universe holding the commits with the given identity, the identity itself if none.
+// renamed reports that no universe holds them but the universe named after them has been updated to other commits
+func (router *Router) identityOwner(identity string) (string, bool, error) {
+	universes, err := router.KubernetesClient.ListUniverses()
+	if err != nil {
+		return "", false, err
+	}
+	renamed := false
+	for _, universe := range universes {
+		if universe.Identity() == identity {
+			return universe.Name, false, nil
+		}
+		renamed = renamed || universe.Name == identity
+	}
+	return identity, renamed, nil
+}
+
+// UniverseIdentity returns the name given by a deterministic name template to the commits of the owner, empty otherwise
+func (router *Router) UniverseIdentity(owner string, jobsParams pipeline.JobsParameters) string {
+	if !router.Namer.Deterministic() {
+		return ""
+	}
+	identity, err := router.Namer.Name(naming.Values{User: owner, JobsParams: jobsParams})
+	if err != nil {
+		log.Printf("error while rendering the identity of the commits of %s: %v", owner, err)
+		return ""
+	}
+	return identity
+}
+
+// NamespaceTaken check if the namespace already exists or is reserved by a queued request
+func (router *Router) NamespaceTaken(namespace string) bool {
+	return router.KubernetesClient.NamespaceAlreadyCreated(namespace) || router.Queue.Has(namespace)
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultBuilds and maxBuilds bound the builds listed for each pipeline
+const (
+	defaultBuilds = 5
+	maxBuilds     = 50
+)
+
+// ListPipelines will return the status and the last builds of the pipeline of each repo of the namespace passed as an api field.
+// The query parameter limit sets the number of builds, the build started by one is reported as triggered
+func (router *Router) ListPipelines() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		limit := defaultBuilds
+		if value := c.Query("limit"); value != "" {
+			var err error
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxBuilds {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxBuilds)})
+				return
+			}
+		}
+		universe, err := router.KubernetesClient.GetUniverse(namespace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// the triggers are resolved for the response only, the tracker persists them
+		router.followBuilds(universe, false)
+		pipelines := map[string]*pipeline.PipelineBuilds{}
+		for repo, job := range universe.Status.Jobs {
+			builds, err := router.CIProvider.Builds(repo, job, namespace, limit)
+			if err == pipeline.ErrNotFound {
+				builds = &pipeline.PipelineBuilds{Pipeline: job, Status: pipeline.BuildNotBuilt, Builds: []pipeline.Build{}}
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			builds.Triggered = universe.Status.Triggers[repo]
+			pipelines[repo] = builds
+		}
+		c.JSON(http.StatusOK, pipelines)
+	}
+}
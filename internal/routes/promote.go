@@ -0,0 +1,143 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/staging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PromoteNamespace will make stable the namespace passed as an api field
+func (router *Router) PromoteNamespace() gin.HandlerFunc {
+	return router.setStable(true)
+}
+
+// DemoteNamespace will make ephemeral the namespace passed as an api field, it expires after the default ttl
+func (router *Router) DemoteNamespace() gin.HandlerFunc {
+	return router.setStable(false)
+}
+
+func (router *Router) setStable(stable bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		router.LoadOrStoreLock(namespace)
+		defer router.Unlock(namespace)
+		universe, err := router.KubernetesClient.GetUniverse(namespace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if universe.Spec.Stable == stable {
+			c.JSON(http.StatusOK, universe)
+			return
+		}
+		if stable {
+			// the sleeper skips the stable universes, so a sleeping one would never be woken up
+			sleeping, err := router.KubernetesClient.IsSleeping(namespace)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if sleeping {
+				c.JSON(http.StatusConflict, gin.H{"error": "the universe is sleeping, wake it up before promoting it"})
+				return
+			}
+			_, err = router.KubernetesClient.UnderMaxStableNsLimit(router.PendingUniverses()...)
+			if err != nil {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+				return
+			}
+			universes, err := router.KubernetesClient.NamespaceManagedList()
+			if err != nil {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+				return
+			}
+			// the universe is checked against the quotas of its owner as if it were new
+			others := []kubernetes.MyNameSpace{}
+			var teams []string
+			for _, other := range universes {
+				if other.Name == namespace {
+					teams = other.Teams
+					continue
+				}
+				others = append(others, other)
+			}
+			if violation := router.Quotas.Check(universe.Spec.Owner, teams, true, others); violation != nil {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": violation.Error(), "quota": violation})
+				return
+			}
+		}
+		err = router.stableSteps(universe, stable).Run()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, universe)
+	}
+}
+
+// stableSteps returns the steps changing the stable flag of the universe, the pipelines are rendered before the namespace
+// and the universe are updated, and every step is reverted if one of the following ones fails
+func (router *Router) stableSteps(universe *kubernetes.Universe, stable bool) *staging.Sequence {
+	namespace := universe.Namespace
+	previous := universe.JobsParameters()
+	var previousExpiry *time.Time
+	if universe.Spec.ExpiresAt != nil {
+		previousExpiry = &universe.Spec.ExpiresAt.Time
+	}
+	expiresAt := router.KubernetesClient.NewExpiry(stable)
+	rendered := []string{}
+	renderPipelines := func(projects []string, stable bool) error {
+		jobsParams := universe.JobsParameters()
+		jobsParams.Stable = stable
+		for _, project := range projects {
+			job := universe.Status.Jobs[project]
+			log.Printf("rendering job %s of namespace %s with stable %v", job, namespace, stable)
+			err := router.CIProvider.RenderPipeline(project, job, namespace, jobsParams)
+			if err != nil {
+				return err
+			}
+			rendered = append(rendered, project)
+		}
+		return nil
+	}
+	steps := staging.NewSequence()
+	steps.Add("pipelines", func() error {
+		projects := []string{}
+		for project := range universe.Status.Jobs {
+			projects = append(projects, project)
+		}
+		err := renderPipelines(projects, stable)
+		if err != nil {
+			// the pipelines rendered by this step are reverted here, since the step is not completed
+			undone := rendered
+			rendered = []string{}
+			if undoErr := renderPipelines(undone, previous.Stable); undoErr != nil {
+				log.Printf("error while restoring pipelines of %s: %v", namespace, undoErr)
+			}
+		}
+		return err
+	}, func() error {
+		undone := rendered
+		rendered = []string{}
+		return renderPipelines(undone, previous.Stable)
+	})
+	steps.Add("namespace", func() error {
+		return router.KubernetesClient.SetStable(namespace, stable, expiresAt)
+	}, func() error {
+		return router.KubernetesClient.SetStable(namespace, previous.Stable, previousExpiry)
+	})
+	steps.Add("universe", func() error {
+		universe.Spec.Stable = stable
+		universe.Spec.ExpiresAt = nil
+		if expiresAt != nil {
+			universe.Spec.ExpiresAt = &metav1.Time{Time: *expiresAt}
+		}
+		return router.KubernetesClient.UpdateUniverse(universe)
+	}, nil)
+	return steps
+}
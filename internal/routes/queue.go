@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lzecca78/one/internal/auth"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/lzecca78/one/internal/queue"
+	"github.com/pkg/errors"
+)
+
+// MoveRequest is the body of the api PUT /queue/:id
+type MoveRequest struct {
+	Position int `json:"position"`
+}
+
+// IsAdmin check if the user of the request is one of the admins of the config
+func (router *Router) IsAdmin(c *gin.Context) bool {
+	user := auth.CurrentUser(c)
+	if user == "" {
+		return false
+	}
+	for _, admin := range router.ViperEnvConfig.GetStringSlice("admins") {
+		if admin == user {
+			return true
+		}
+	}
+	return false
+}
+
+// EnqueueStaging queues the creation of the staging entity until a slot frees up, only admins can set a priority
+func (router *Router) EnqueueStaging(c *gin.Context, jobsParams pipeline.JobsParameters, namespace string) {
+	entry := queue.Entry{
+		Namespace:  namespace,
+		Owner:      auth.CurrentUser(c),
+		Teams:      auth.CurrentTeams(c),
+		JobsParams: jobsParams,
+	}
+	if router.IsAdmin(c) {
+		entry.Priority = jobsParams.Priority
+	}
+	queued, err := router.Queue.Push(entry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/api/queue/%s", queued.ID))
+	c.JSON(http.StatusAccepted, queued)
+}
+
+// ListQueue will return the queued requests in order
+func (router *Router) ListQueue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entries, err := router.Queue.List()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// GetQueued will return the queued request passed as an api field with its position.
+// A request already started redirects to the operation creating its universe
+func (router *Router) GetQueued() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entries, err := router.Queue.List()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, entry := range entries {
+			if entry.ID == c.Param("id") {
+				c.JSON(http.StatusOK, entry)
+				return
+			}
+		}
+		if op, ok := router.Operations.FromQueue(c.Param("id")); ok {
+			c.Header("Location", fmt.Sprintf("/api/operations/%s", op.ID()))
+			c.JSON(http.StatusSeeOther, op.Status())
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": queue.ErrNotFound.Error()})
+	}
+}
+
+// CancelQueued will remove the queued request passed as an api field, only its owner or an admin can cancel it
+func (router *Router) CancelQueued() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		entries, err := router.Queue.List()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, entry := range entries {
+			if entry.ID != id {
+				continue
+			}
+			if entry.Owner != auth.CurrentUser(c) && !router.IsAdmin(c) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "only the owner or an admin can cancel the request"})
+				return
+			}
+			_, err := router.Queue.Cancel(id)
+			if err == queue.ErrNotFound {
+				break
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusNoContent, nil)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": queue.ErrNotFound.Error()})
+	}
+}
+
+// MoveQueued will move the queued request passed as an api field to a new position, admin only
+func (router *Router) MoveQueued() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !router.IsAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only an admin can reorder the queue"})
+			return
+		}
+		var request MoveRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		moved, err := router.Queue.Move(c.Param("id"), request.Position)
+		if err == queue.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, moved)
+	}
+}
+
+// StartQueued starts the creation of a queued request if there is a free slot for it.
+// The creations started before are counted by the checks, so a free slot starts a single request
+func (router *Router) StartQueued(precondition func(*pipeline.JobsParameters) (bool, error)) func(queue.Entry) (bool, error) {
+	return func(entry queue.Entry) (bool, error) {
+		router.LockAdmission()
+		defer router.UnlockAdmission()
+		router.LoadOrStoreLock(entry.Namespace)
+		if router.KubernetesClient.NamespaceAlreadyCreated(entry.Namespace) {
+			router.Unlock(entry.Namespace)
+			return false, errors.Errorf("namespace %s already exists", entry.Namespace)
+		}
+		check, err := precondition(&entry.JobsParams)
+		if err != nil || !check {
+			router.Unlock(entry.Namespace)
+			return false, nil
+		}
+		universes, err := router.KubernetesClient.NamespaceManagedList()
+		if err != nil {
+			router.Unlock(entry.Namespace)
+			return false, nil
+		}
+		universes = kubernetes.WithPending(universes, router.PendingUniverses())
+		if violation := router.Quotas.Check(entry.Owner, entry.Teams, entry.JobsParams.Stable, universes); violation != nil {
+			router.Unlock(entry.Namespace)
+			return false, nil
+		}
+		// the lock is released once the creation is finished
+		op := router.StartStagingCreation(entry.JobsParams, entry.Namespace, entry.Owner, entry.Teams)
+		op.Dequeued(entry.ID)
+		return true, nil
+	}
+}
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lzecca78/one/internal/auth"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/quota"
+)
+
+// CheckQuotas returns the quota of the current user or of its teams that a new universe would exceed, nil if it can be created
+func (router *Router) CheckQuotas(c *gin.Context, stable bool) (*quota.Violation, error) {
+	universes, err := router.KubernetesClient.NamespaceManagedList()
+	if err != nil {
+		return nil, err
+	}
+	universes = kubernetes.WithPending(universes, router.PendingUniverses())
+	return router.Quotas.Check(auth.CurrentUser(c), auth.CurrentTeams(c), stable, universes), nil
+}
+
+// PendingUniverses returns the universes whose creation is in progress, their namespace could not exist yet
+func (router *Router) PendingUniverses() []kubernetes.MyNameSpace {
+	pending := []kubernetes.MyNameSpace{}
+	for _, op := range router.Operations.Pending() {
+		pending = append(pending, kubernetes.MyNameSpace{
+			Name:      op.Namespace,
+			Stable:    op.Stable,
+			Status:    "Active",
+			Owner:     op.Owner,
+			Teams:     op.Teams,
+			CreatedAt: op.CreatedAt,
+		})
+	}
+	return pending
+}
+
+// LockAdmission must be held from the checks of the limits to the start of the creation,
+// so the creation is counted by the checks of the concurrent requests. It is taken before the lock of the namespace
+func (router *Router) LockAdmission() {
+	router.admission.Lock()
+}
+
+// UnlockAdmission releases the lock taken by LockAdmission
+func (router *Router) UnlockAdmission() {
+	router.admission.Unlock()
+}
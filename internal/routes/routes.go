@@ -3,11 +3,17 @@ package routes
 import (
 	"log"
 	"net/http"
+	"sync"
 
+	"github.com/lzecca78/one/internal/ci"
 	"github.com/lzecca78/one/internal/git"
-	"github.com/lzecca78/one/internal/jenkins"
 	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/naming"
+	"github.com/lzecca78/one/internal/queue"
+	"github.com/lzecca78/one/internal/quota"
+	"github.com/lzecca78/one/internal/reconciler"
 	"github.com/lzecca78/one/internal/route53"
+	"github.com/lzecca78/one/internal/staging"
 	"github.com/lzecca78/one/internal/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
@@ -17,7 +23,7 @@ import (
 type Clients struct {
 	ViperEnvConfig   *viper.Viper
 	GitClient        *git.Client
-	JenkinsClient    *jenkins.JenkinsClient
+	CIProvider       ci.Provider
 	KubernetesClient *kubernetes.Client
 	R53client        *route53.RClient
 }
@@ -26,21 +32,39 @@ type Clients struct {
 type Router struct {
 	*Clients
 	*utils.Locks
+	Operations *staging.Operations
+	Namer      *naming.Namer
+	Quotas     *quota.Quotas
+	Queue      *queue.Queue
+	Reconciler *reconciler.Reconciler
+	// admission serializes the checks of the limits with the start of the creations
+	admission sync.Mutex
 }
 
 // NewRouter  setup the Router struct
-func NewRouter(clients *Clients, locks *utils.Locks) *Router {
+func NewRouter(clients *Clients, locks *utils.Locks, operations *staging.Operations, namer *naming.Namer, quotas *quota.Quotas, queue *queue.Queue, reconciler *reconciler.Reconciler) *Router {
 	return &Router{
-		Clients: clients,
-		Locks:   locks,
+		Clients:    clients,
+		Locks:      locks,
+		Operations: operations,
+		Namer:      namer,
+		Quotas:     quotas,
+		Queue:      queue,
+		Reconciler: reconciler,
 	}
 }
 
-// CheckNamespaceSecret is a specific auth function for cronjob
+// CheckNamespaceSecret is a specific auth function for the cli
 func (router *Router) CheckNamespaceSecret(f gin.HandlerFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
-		_, projectJobMap, err := router.KubernetesClient.GetConfigMap(namespace, namespace)
+		// the legacy universes are migrated together with their secret
+		_, err := router.KubernetesClient.GetUniverse(namespace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		deleteSecret, err := router.KubernetesClient.GetDeleteSecret(namespace)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -50,16 +74,12 @@ func (router *Router) CheckNamespaceSecret(f gin.HandlerFunc) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "delete_secret query parameter not found"})
 			return
 		}
-		if mapDeleteSecret, ok := projectJobMap[kubernetes.DeleteSecret]; ok {
-			if queryDeleteSecret == mapDeleteSecret {
-				f(c)
-			} else {
-				log.Printf("%s query param %s not matching %s", kubernetes.DeleteSecret, queryDeleteSecret, mapDeleteSecret)
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "delete_secret not matching"})
-				return
-			}
-
+		if queryDeleteSecret != deleteSecret {
+			log.Printf("%s query param not matching for namespace %s", kubernetes.DeleteSecret, namespace)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "delete_secret not matching"})
+			return
 		}
+		f(c)
 	}
 
 }
@@ -67,38 +87,58 @@ func (router *Router) CheckNamespaceSecret(f gin.HandlerFunc) gin.HandlerFunc {
 // DeleteNamespace will delete the namespace passed as an api field
 func (router *Router) DeleteNamespace() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		namespace := c.Param("namespace")
-		//get lock for for chosen namespace
-		router.LoadOrStoreLock(namespace)
-		defer router.Unlock(namespace)
-		//delete records in route53
-		cfgMapData, projectJobMap, err := router.KubernetesClient.GetConfigMap(namespace, namespace)
+		err := router.TeardownNamespace(c.Param("namespace"))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		log.Printf("will delete jobs %v", projectJobMap)
-		err = router.JenkinsClient.DeleteFolder(namespace)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		for project, records := range cfgMapData.ProjectsWithDetails {
-			for _, record := range records.Ingresses {
-				log.Printf("deleting record %s for project %s in namespace %s", record, project, namespace)
-				router.R53client.DeleteRecordSet(record)
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
+
+// TeardownNamespace will delete the jenkins jobs, the dns records and the namespace of a universe
+func (router *Router) TeardownNamespace(namespace string) error {
+	//get lock for for chosen namespace
+	router.LoadOrStoreLock(namespace)
+	defer router.Unlock(namespace)
+	//delete records in route53
+	universe, err := router.KubernetesClient.GetUniverse(namespace)
+	if err != nil {
+		return err
+	}
+	log.Printf("will delete jobs %v", universe.Status.Jobs)
+	err = router.CIProvider.Teardown(namespace)
+	if err != nil {
+		return err
+	}
+	//the namespace is deleted anyway, the records left behind are reported as drift
+	var recordErr error
+	for project, records := range universe.Status.Projects {
+		for _, record := range records.Ingresses {
+			log.Printf("deleting record %s for project %s in namespace %s", record, project, namespace)
+			_, err := router.R53client.DeleteRecordSet(record)
+			if err != nil {
+				log.Printf("error while deleting record %s in namespace %s: %v", record, namespace, err)
+				recordErr = err
 			}
 		}
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		//delete namespace
-		err = router.KubernetesClient.DeleteNamespace(namespace)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+	//delete namespace
+	err = router.KubernetesClient.DeleteNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	return recordErr
+}
+
+// GetOperation will return the progress of the operation passed as an api field
+func (router *Router) GetOperation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op, ok := router.Operations.Get(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "operation not found"})
 			return
 		}
-		c.JSON(http.StatusNoContent, nil)
+		c.JSON(http.StatusOK, op.Status())
 	}
 }
@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// SleepUniverse scales to zero the workloads of the universe
+func (router *Router) SleepUniverse(namespace string) error {
+	router.LoadOrStoreLock(namespace)
+	defer router.Unlock(namespace)
+	return router.KubernetesClient.Sleep(namespace)
+}
+
+// WakeUniverse restores the workloads of the universe put to sleep.
+// Sleeping universes do not count against the maximum number of universes, so it is checked again before waking one up,
+// counting the universes being created
+func (router *Router) WakeUniverse(namespace string) error {
+	router.LockAdmission()
+	defer router.UnlockAdmission()
+	router.LoadOrStoreLock(namespace)
+	defer router.Unlock(namespace)
+	sleeping, err := router.KubernetesClient.IsSleeping(namespace)
+	if err != nil {
+		return err
+	}
+	if sleeping {
+		_, err = router.KubernetesClient.UnderMaxNsLimit(router.PendingUniverses()...)
+		if err != nil {
+			return err
+		}
+	}
+	return router.KubernetesClient.Wake(namespace)
+}
+
+// SleepNamespace will put to sleep the namespace passed as an api field
+func (router *Router) SleepNamespace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		err := router.SleepUniverse(namespace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"namespace": namespace, "sleeping": true})
+	}
+}
+
+// WakeNamespace will wake up the namespace passed as an api field
+func (router *Router) WakeNamespace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		err := router.WakeUniverse(namespace)
+		if errors.Cause(err) == kubernetes.ErrUniverseLimit {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"namespace": namespace, "sleeping": false})
+	}
+}
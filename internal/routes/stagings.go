@@ -0,0 +1,197 @@
+package routes
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"time"
+
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/lzecca78/one/internal/staging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StartStagingCreation creates the staging entity in background and returns the operation to poll for its progress.
+// The lock of the namespace must be held by the caller: it is released once the creation is finished
+func (router *Router) StartStagingCreation(jobsParams pipeline.JobsParameters, namespace, owner string, teams []string) *staging.Operation {
+	creation := &stagingCreation{
+		router:     router,
+		jobsParams: jobsParams,
+		namespace:  namespace,
+		owner:      owner,
+		teams:      teams,
+	}
+	steps := creation.steps()
+	op := router.Operations.Create(namespace, owner, teams, jobsParams.Stable, steps)
+	steps.Track(op)
+	go func() {
+		defer router.Unlock(namespace)
+		err := steps.Run()
+		if err != nil {
+			log.Printf("creation of namespace %s failed: %v", namespace, err)
+			op.Finish(nil, err)
+			return
+		}
+		op.Finish(creation.kresp, nil)
+	}()
+	return op
+}
+
+// stagingCreation holds the state shared by the steps of the creation of a staging entity
+type stagingCreation struct {
+	router        *Router
+	jobsParams    pipeline.JobsParameters
+	namespace     string
+	owner         string
+	teams         []string
+	kresp         *kubernetes.CloneIngressResponse
+	projectJobMap map[string]string
+	triggers      map[string]*pipeline.Trigger
+	cloned        map[string][]string
+	externalNames []string
+	records       []string
+}
+
+// steps returns the steps needed to create a staging entity, every step is reverted if one of the following ones fails
+func (s *stagingCreation) steps() *staging.Sequence {
+	router := s.router
+	namespace := s.namespace
+	expiresAt := router.KubernetesClient.NewExpiry(s.jobsParams.Stable)
+	provenance := kubernetes.Provenance{
+		Owner:            s.owner,
+		Teams:            s.teams,
+		Purpose:          s.jobsParams.Purpose,
+		CreatedAt:        time.Now(),
+		CommitPerProject: s.jobsParams.CommitPerProject,
+	}
+	steps := staging.NewSequence()
+	steps.Add("namespace", func() error {
+		return router.KubernetesClient.CreateNamespace(namespace, s.jobsParams.Stable, expiresAt, provenance)
+	}, func() error {
+		return router.KubernetesClient.DeleteNamespace(namespace)
+	})
+	steps.Add("quota", func() error {
+		return router.KubernetesClient.ApplyProfile(namespace, s.jobsParams.Profile)
+	}, nil)
+	selected := make([]string, 0, len(s.jobsParams.CommitPerProject))
+	unselected := []string{}
+	for _, repo := range router.CIProvider.Repos() {
+		if _, ok := s.jobsParams.CommitPerProject[repo]; ok {
+			selected = append(selected, repo)
+		} else {
+			unselected = append(unselected, repo)
+		}
+	}
+	all := append(append([]string{}, selected...), unselected...)
+	steps.Add("ingresses", func() error {
+		keys := all
+		// a partial universe exposes only the selected projects, the others are served by the source namespace
+		if s.jobsParams.Partial {
+			keys = selected
+		}
+		//clone ingress from source namespace (staging) with custom new values
+		var err error
+		s.kresp, err = router.KubernetesClient.CloneIngresses(namespace, keys)
+		return err
+	}, nil)
+	steps.Add("routes", func() error {
+		if !s.jobsParams.Partial {
+			return nil
+		}
+		// created before the cloned resources, so the services of the projects not selected are not cloned
+		var err error
+		s.externalNames, err = router.KubernetesClient.RouteToSource(namespace, unselected)
+		return err
+	}, nil)
+	steps.Add("resources", func() error {
+		skipped := selected
+		// the workloads of the projects not selected are served by the source namespace too
+		if s.jobsParams.Partial {
+			skipped = all
+		}
+		//clone the accessory resources following the clone policies
+		var err error
+		s.cloned, err = router.KubernetesClient.CloneResources(namespace, skipped)
+		return err
+	}, nil)
+	deleteRecords := func() error {
+		var undoErr error
+		for _, record := range s.records {
+			_, err := router.R53client.DeleteRecordSet(record)
+			if err != nil {
+				log.Printf("error while deleting record %s: %v", record, err)
+				undoErr = err
+			}
+		}
+		return undoErr
+	}
+	steps.Add("dns", func() error {
+		for _, ingressList := range s.kresp.ProjectsWithDetails {
+			for _, ingressHost := range ingressList.Ingresses {
+				//create record for each ingress created previously
+				resp, err := router.R53client.CreateRecordSet(ingressHost)
+				if err != nil {
+					// records of this step are reverted here, since the step is not completed
+					deleteRecords()
+					return err
+				}
+				s.records = append(s.records, ingressHost)
+				log.Println(resp)
+			}
+		}
+		return nil
+	}, deleteRecords)
+	steps.Add("pipelines", func() error {
+		//initialization of all pipelines of all projects describe in the main config file with custom parameters(branch, namespace and commit)
+		var err error
+		// the owner and the hosts are available to the templates of the pipelines
+		s.jobsParams.Owner = s.owner
+		s.jobsParams.Hosts = map[string][]string{}
+		for project, details := range s.kresp.ProjectsWithDetails {
+			s.jobsParams.Hosts[project] = details.Ingresses
+		}
+		s.projectJobMap, s.triggers, err = router.CIProvider.CreatePipelines(&s.jobsParams, namespace)
+		if err != nil {
+			// the pipeline set could have been created before the failure
+			if teardownErr := router.CIProvider.Teardown(namespace); teardownErr != nil {
+				log.Printf("error while deleting pipelines of %s: %v", namespace, teardownErr)
+			}
+		}
+		return err
+	}, func() error {
+		return router.CIProvider.Teardown(namespace)
+	})
+	steps.Add("persistence", func() error {
+		// enrich with ci job status
+		js, err := router.CIProvider.Status(namespace)
+		if err != nil {
+			return err
+		}
+		newCloneIngResp := kubernetes.EnrichCloneIngressResp(js, s.kresp, &s.jobsParams)
+		var deleteSecret [32]byte
+		_, err = rand.Read(deleteSecret[:])
+		if err != nil {
+			return err
+		}
+		// adding creation of random string for cli authentication on delete api
+		err = router.KubernetesClient.CreateDeleteSecret(namespace, base64.StdEncoding.EncodeToString(deleteSecret[:]))
+		if err != nil {
+			return err
+		}
+		universe := kubernetes.NewUniverse(&s.jobsParams, newCloneIngResp, s.projectJobMap)
+		universe.Status.Cloned = s.cloned
+		universe.Status.Triggers = s.triggers
+		universe.Spec.Owner = s.owner
+		universe.Spec.Identity = router.UniverseIdentity(s.owner, s.jobsParams)
+		if len(s.externalNames) > 0 {
+			universe.Status.Cloned[kubernetes.KindExternalName] = s.externalNames
+		}
+		if expiresAt != nil {
+			universe.Spec.ExpiresAt = &metav1.Time{Time: *expiresAt}
+		}
+		//create the universe as persistence layer with CloneIngressResponse struct inside
+		return router.KubernetesClient.CreateUniverse(universe)
+	}, nil)
+	return steps
+}
@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"log"
+
+	"github.com/lzecca78/one/internal/ci"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/pipeline"
+)
+
+// followBuilds resolves the pending triggers of the universe and returns the builds started by them, by project.
+// The sha of a finished build is recorded in the universe, flagged if it is not the requested one, and its trigger is done.
+// The builds of the triggers already done are returned only with all. The changes are made on the given universe only,
+// it reports if there are any to persist. It returns nil if the provider does not track the builds
+func (router *Router) followBuilds(universe *kubernetes.Universe, all bool) (map[string]*pipeline.Build, bool) {
+	tracker, ok := router.CIProvider.(ci.BuildTracker)
+	if !ok {
+		return nil, false
+	}
+	builds := map[string]*pipeline.Build{}
+	changed := false
+	for project, trigger := range universe.Status.Triggers {
+		if trigger.Pending() {
+			err := tracker.ResolveTrigger(trigger)
+			if err != nil {
+				log.Printf("error while resolving trigger of project %s in namespace %s: %v", project, universe.Namespace, err)
+				continue
+			}
+			changed = changed || !trigger.Pending()
+		}
+		job, ok := universe.Status.Jobs[project]
+		if !ok || trigger.State != pipeline.TriggerStarted || (trigger.Done() && !all) {
+			continue
+		}
+		build, err := tracker.Build(project, job, universe.Namespace, trigger.Number)
+		if err != nil {
+			log.Printf("error while getting build %d of pipeline %s: %v", trigger.Number, job, err)
+			continue
+		}
+		builds[project] = build
+		if build.FinishedAt != nil && trigger.FinishedAt == nil {
+			trigger.FinishedAt = build.FinishedAt
+			if build.Sha != "" {
+				universe.SetDeployedSha(project, build.Sha)
+			}
+			changed = true
+		}
+	}
+	return builds, changed
+}
+
+// TrackBuilds follows the builds started by one in the universe until they are done, so the triggers are resolved
+// before jenkins forgets their queue items and the deployed shas are recorded. It is the only one persisting the
+// triggers, the apis follow them on their own copy of the universe
+func (router *Router) TrackBuilds(namespace string) error {
+	router.LoadOrStoreLock(namespace)
+	defer router.Unlock(namespace)
+	universe, err := router.KubernetesClient.GetUniverse(namespace)
+	if err != nil {
+		return err
+	}
+	for _, trigger := range universe.Status.Triggers {
+		if !trigger.Done() {
+			_, changed := router.followBuilds(universe, false)
+			if !changed {
+				return nil
+			}
+			// the triggers are followed again on the next run if they are not persisted
+			return router.KubernetesClient.UpdateUniverse(universe)
+		}
+	}
+	return nil
+}
+
+// PipelineStatuses returns the status of the pipeline of each project of the universe, by pipeline name.
+// The status of a pipeline with a tracked trigger is the one of the build started by one, the last build otherwise
+func (router *Router) PipelineStatuses(universe *kubernetes.Universe) (pipeline.JobsStatuses, error) {
+	statuses, err := router.CIProvider.Status(universe.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	builds, _ := router.followBuilds(universe, true)
+	for project, trigger := range universe.Status.Triggers {
+		job, ok := universe.Status.Jobs[project]
+		if !ok {
+			continue
+		}
+		if trigger.State == pipeline.TriggerQueued {
+			statuses[job] = pipeline.BuildQueued
+			continue
+		}
+		if build, ok := builds[project]; ok {
+			statuses[job] = build.Result
+		}
+	}
+	return statuses, nil
+}
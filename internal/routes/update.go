@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lzecca78/one/internal/git"
+)
+
+// UpdateRequest is the body of the api PATCH /stagings/:namespace, with the new commit of each project to change
+type UpdateRequest struct {
+	CommitPerProject git.CommitSpec
+}
+
+// UpdateNamespace will change in place the branch of the projects of the namespace passed as an api field
+func (router *Router) UpdateNamespace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		var request UpdateRequest
+		err := c.BindJSON(&request)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(request.CommitPerProject) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no project to update"})
+			return
+		}
+		router.LoadOrStoreLock(namespace)
+		defer router.Unlock(namespace)
+		universe, err := router.KubernetesClient.GetUniverse(namespace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// every project is checked before changing anything
+		for project, commit := range request.CommitPerProject {
+			if _, ok := universe.Status.Jobs[project]; !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("project %s not found in namespace %s", project, namespace)})
+				return
+			}
+			if commit.Branch == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("branch of project %s is empty", project)})
+				return
+			}
+		}
+		for project, commit := range request.CommitPerProject {
+			log.Printf("updating project %s in namespace %s to branch %s", project, namespace, commit.Branch)
+			build := universe.JobsParameters().BuildContext(project)
+			build.Commit = commit
+			trigger, err := router.CIProvider.UpdatePipeline(project, universe.Status.Jobs[project], namespace, build)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			universe.SetTrigger(project, trigger)
+			if universe.Spec.CommitPerProject == nil {
+				universe.Spec.CommitPerProject = git.CommitSpec{}
+			}
+			universe.Spec.CommitPerProject[project] = commit
+			// the same commits are found in this universe by the following creations
+			universe.Spec.Identity = router.UniverseIdentity(universe.Spec.Owner, *universe.JobsParameters())
+			err = router.KubernetesClient.SetCommits(namespace, universe.Spec.CommitPerProject)
+			if err != nil {
+				log.Printf("error while recording commits of namespace %s: %v", namespace, err)
+			}
+			if details, ok := universe.Status.Projects[project]; ok {
+				details.CVSRefs = commit
+			}
+			// the sha of the new build is checked once it completes
+			universe.SetDeployedSha(project, "")
+			// the jobs already updated are persisted even if a following one fails
+			err = router.KubernetesClient.UpdateUniverse(universe)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, universe)
+	}
+}
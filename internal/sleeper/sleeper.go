@@ -0,0 +1,204 @@
+package sleeper
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lzecca78/one/internal/config"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// leaseName is the name of the lease used to elect the replica running the sleeper
+const leaseName = "one-sleeper"
+
+// WorkingHours describes when the universes are awake, outside of them the universes are put to sleep
+type WorkingHours struct {
+	Enabled  bool     `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Timezone string   `json:"timezone" yaml:"timezone" mapstructure:"timezone"`
+	Start    string   `json:"start" yaml:"start" mapstructure:"start"`
+	End      string   `json:"end" yaml:"end" mapstructure:"end"`
+	Days     []string `json:"days" yaml:"days" mapstructure:"days"`
+}
+
+// schedule is the parsed form of WorkingHours
+type schedule struct {
+	location *time.Location
+	start    time.Duration
+	end      time.Duration
+	days     map[time.Weekday]bool
+}
+
+// Sleeper puts the not stable universes to sleep outside of working hours and wakes them up when they start
+type Sleeper struct {
+	client   *kubernetes.Client
+	elector  *kubernetes.LeaderElector
+	sleep    func(namespace string) error
+	wake     func(namespace string) error
+	schedule *schedule
+	interval time.Duration
+	awake    *bool
+}
+
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid time %s, expected hh:mm", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+func (w WorkingHours) schedule() (*schedule, error) {
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid timezone %s", w.Timezone)
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return nil, err
+	}
+	if start >= end {
+		return nil, errors.Errorf("start %s must be before end %s", w.Start, w.End)
+	}
+	days := map[time.Weekday]bool{}
+	for _, day := range w.Days {
+		found := false
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if strings.EqualFold(day, weekday.String()[:3]) || strings.EqualFold(day, weekday.String()) {
+				days[weekday] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("invalid day %s", day)
+		}
+	}
+	return &schedule{location: location, start: start, end: end, days: days}, nil
+}
+
+// contains check if the time is inside the working hours
+func (s *schedule) contains(t time.Time) bool {
+	local := t.In(s.location)
+	if !s.days[local.Weekday()] {
+		return false
+	}
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+	elapsed := local.Sub(midnight)
+	return elapsed >= s.start && elapsed < s.end
+}
+
+// NewSleeper initialize the Sleeper, nil is returned when working hours are disabled.
+// The sleep and wake functions are the same used by the apis
+func NewSleeper(v *viper.Viper, client *kubernetes.Client, sleep, wake func(namespace string) error) *Sleeper {
+	var workingHours WorkingHours
+	err := v.UnmarshalKey("workingHours", &workingHours)
+	if err != nil {
+		log.Fatalf("unable to unmarshal working hours: %v", err)
+	}
+	if !workingHours.Enabled {
+		log.Println("working hours disabled, universes are never put to sleep automatically")
+		return nil
+	}
+	schedule, err := workingHours.schedule()
+	if err != nil {
+		log.Fatalf("invalid working hours: %v", err)
+	}
+	interval, err := time.ParseDuration(config.CheckAndGetString(v, "SLEEPER_INTERVAL"))
+	if err != nil {
+		log.Fatal("failed converting SLEEPER_INTERVAL to duration:", err)
+	}
+	sleeper := &Sleeper{
+		client:   client,
+		sleep:    sleep,
+		wake:     wake,
+		schedule: schedule,
+		interval: interval,
+	}
+	if config.CheckAndGetBool(v, "LEADER_ELECTION") {
+		// the lease outlives a missed renewal, so the leader does not flap between replicas
+		sleeper.elector = client.NewLeaderElector(leaseName, 2*interval)
+	}
+	return sleeper
+}
+
+// Run checks the working hours every interval, it never returns
+func (s *Sleeper) Run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.Check(time.Now())
+		<-ticker.C
+	}
+}
+
+// observedAwake returns whether most of the not stable universes are awake, fallback when there are as many awake as sleeping
+func observedAwake(namespaces []kubernetes.MyNameSpace, fallback bool) bool {
+	balance := 0
+	for _, namespace := range namespaces {
+		if namespace.Stable || namespace.Status != "Active" {
+			continue
+		}
+		if namespace.Sleeping {
+			balance--
+		} else {
+			balance++
+		}
+	}
+	if balance == 0 {
+		return fallback
+	}
+	return balance > 0
+}
+
+// Check puts to sleep or wakes up the universes when the working hours end or start.
+// Nothing is done between the transitions, so the universes woken up or put to sleep by hand are left as they are.
+// On the first check the state before the restart is taken from the universes, so a transition missed meanwhile is done
+func (s *Sleeper) Check(now time.Time) {
+	awake := s.schedule.contains(now)
+	if s.awake == nil {
+		namespaces, err := s.client.NamespaceManagedList()
+		if err != nil {
+			log.Printf("sleeper unable to list namespaces: %v", err)
+			return
+		}
+		observed := observedAwake(namespaces, awake)
+		s.awake = &observed
+	}
+	if *s.awake == awake {
+		return
+	}
+	// the followers track the transitions too, so a new leader does not act on a transition already handled
+	if s.elector != nil && !s.elector.IsLeader() {
+		s.awake = &awake
+		return
+	}
+	namespaces, err := s.client.NamespaceManagedList()
+	if err != nil {
+		log.Printf("sleeper unable to list namespaces: %v", err)
+		return
+	}
+	s.awake = &awake
+	for _, namespace := range namespaces {
+		if namespace.Stable || namespace.Status != "Active" || namespace.Sleeping != awake {
+			continue
+		}
+		if awake {
+			log.Printf("working hours started, waking up namespace %s", namespace.Name)
+			err = s.wake(namespace.Name)
+		} else {
+			log.Printf("working hours ended, putting namespace %s to sleep", namespace.Name)
+			err = s.sleep(namespace.Name)
+		}
+		if errors.Cause(err) == kubernetes.ErrUniverseLimit {
+			log.Printf("namespace %s left asleep: %v", namespace.Name, err)
+		} else if err != nil {
+			log.Printf("sleeper unable to change namespace %s: %v", namespace.Name, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package sleeper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lzecca78/one/internal/kubernetes"
+)
+
+func TestScheduleContains(t *testing.T) {
+	workingHours := WorkingHours{
+		Timezone: "UTC",
+		Start:    "08:00",
+		End:      "20:00",
+		Days:     []string{"Mon", "Tue", "Wed", "Thu", "friday"},
+	}
+	schedule, err := workingHours.schedule()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := map[string]bool{
+		"2019-10-14T07:59:00Z": false,
+		"2019-10-14T08:00:00Z": true,
+		"2019-10-18T19:59:00Z": true,
+		"2019-10-18T20:00:00Z": false,
+		"2019-10-19T12:00:00Z": false,
+	}
+	for value, expected := range cases {
+		now, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := schedule.contains(now); got != expected {
+			t.Errorf("%s: expected %v, got %v", value, expected, got)
+		}
+	}
+}
+
+func TestScheduleInvalid(t *testing.T) {
+	invalid := []WorkingHours{
+		{Timezone: "UTC", Start: "20:00", End: "08:00"},
+		{Timezone: "UTC", Start: "8", End: "20:00"},
+		{Timezone: "UTC", Start: "08:00", End: "20:00", Days: []string{"Funday"}},
+		{Timezone: "Nowhere/City", Start: "08:00", End: "20:00"},
+	}
+	for _, workingHours := range invalid {
+		_, err := workingHours.schedule()
+		if err == nil {
+			t.Errorf("expected error for %+v", workingHours)
+		}
+	}
+}
+
+func TestObservedAwake(t *testing.T) {
+	namespaces := []kubernetes.MyNameSpace{
+		{Name: "ms-a", Status: "Active", Sleeping: true},
+		{Name: "ms-b", Status: "Active", Sleeping: true},
+		{Name: "ms-c", Status: "Active"},
+		{Name: "ms-d", Status: "Active", Stable: true},
+		{Name: "ms-e", Status: "Terminating"},
+	}
+	if observedAwake(namespaces, true) {
+		t.Error("expected the universes to be observed asleep")
+	}
+	if !observedAwake(namespaces[1:], true) {
+		t.Error("expected the fallback when as many universes are awake as sleeping")
+	}
+	if observedAwake(nil, false) {
+		t.Error("expected the fallback without universes")
+	}
+}
@@ -0,0 +1,362 @@
+package staging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// States of an operation and of its steps
+const (
+	StatePending      = "pending"
+	StateRunning      = "running"
+	StateSucceeded    = "succeeded"
+	StateFailed       = "failed"
+	StateReverted     = "reverted"
+	StateRevertFailed = "revert_failed"
+	// operationsRetention is the time a finished operation remains available for polling
+	operationsRetention = 24 * time.Hour
+	// abandonedAfter is the time after which an operation still not finished is considered abandoned,
+	// e.g. by a replica restarted while running it
+	abandonedAfter = time.Hour
+	// maxRetries is the number of attempts to update the operations changed concurrently by another replica
+	maxRetries = 5
+)
+
+// Store persists the operations, Save must fail with false when the version is not the loaded one
+type Store interface {
+	Load() ([]byte, string, error)
+	Save(data []byte, version string) (bool, error)
+}
+
+// Tracker is notified by a Sequence about the progress of its steps
+type Tracker interface {
+	StepStarted(name string)
+	StepCompleted(name string, err error)
+	StepReverted(name string, err error)
+}
+
+// StepStatus describes the progress of a single step of an operation
+type StepStatus struct {
+	Name       string     `json:"name"`
+	State      string     `json:"state"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// OperationStatus is the snapshot of an operation returned by the api /operations/:id
+type OperationStatus struct {
+	ID         string            `json:"id"`
+	Namespace  string            `json:"namespace"`
+	Owner      string            `json:"owner,omitempty"`
+	Teams      []string          `json:"teams,omitempty"`
+	Stable     bool              `json:"stable"`
+	QueueID    string            `json:"queue_id,omitempty"`
+	State      string            `json:"state"`
+	Steps      []StepStatus      `json:"steps"`
+	Result     interface{}       `json:"result,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	FailedStep string            `json:"failed_step,omitempty"`
+	UndoErrors map[string]string `json:"undo_errors,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Operation is a staging creation running in background
+type Operation struct {
+	mu       sync.Mutex
+	status   OperationStatus
+	registry *Operations
+}
+
+// ID returns the identifier of the operation
+func (o *Operation) ID() string {
+	return o.status.ID
+}
+
+// Status returns a copy of the current status of the operation
+func (o *Operation) Status() OperationStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	status := o.status
+	status.Steps = append([]StepStatus{}, o.status.Steps...)
+	return status
+}
+
+// StepStarted implements Tracker
+func (o *Operation) StepStarted(name string) {
+	o.updateStep(name, func(step *StepStatus) {
+		now := time.Now()
+		step.State = StateRunning
+		step.StartedAt = &now
+	})
+}
+
+// StepCompleted implements Tracker
+func (o *Operation) StepCompleted(name string, err error) {
+	o.updateStep(name, func(step *StepStatus) {
+		now := time.Now()
+		step.FinishedAt = &now
+		step.State = StateSucceeded
+		if err != nil {
+			step.State = StateFailed
+			step.Error = err.Error()
+		}
+	})
+}
+
+// StepReverted implements Tracker
+func (o *Operation) StepReverted(name string, err error) {
+	o.updateStep(name, func(step *StepStatus) {
+		step.State = StateReverted
+		if err != nil {
+			step.State = StateRevertFailed
+			step.Error = err.Error()
+		}
+	})
+}
+
+// Finish stores the final result or error of the operation
+func (o *Operation) Finish(result interface{}, err error) {
+	o.finish(result, err)
+	o.persist()
+}
+
+func (o *Operation) finish(result interface{}, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	o.status.FinishedAt = &now
+	if err != nil {
+		o.status.State = StateFailed
+		o.status.Error = err.Error()
+		if stepErr, ok := err.(*StepError); ok {
+			o.status.FailedStep = stepErr.Step
+			o.status.UndoErrors = stepErr.UndoErrors
+		}
+		return
+	}
+	o.status.State = StateSucceeded
+	o.status.Result = result
+}
+
+func (o *Operation) updateStep(name string, update func(*StepStatus)) {
+	o.mu.Lock()
+	o.status.State = StateRunning
+	for idx := range o.status.Steps {
+		if o.status.Steps[idx].Name == name {
+			update(&o.status.Steps[idx])
+			break
+		}
+	}
+	o.mu.Unlock()
+	o.persist()
+}
+
+// Dequeued links the operation to the queued request it was started from
+func (o *Operation) Dequeued(queueID string) {
+	o.mu.Lock()
+	o.status.QueueID = queueID
+	o.mu.Unlock()
+	o.persist()
+}
+
+// persist stores the status of the operation, so it can be polled from every replica.
+// The status is read while holding the lock of the registry, so a stale status never overwrites a newer one
+func (o *Operation) persist() {
+	if o.registry == nil || o.registry.store == nil {
+		return
+	}
+	o.registry.persisting.Lock()
+	defer o.registry.persisting.Unlock()
+	status := o.Status()
+	err := o.registry.update(func(statuses map[string]OperationStatus) {
+		statuses[status.ID] = status
+	})
+	if err != nil {
+		log.Printf("error while persisting operation %s: %v", status.ID, err)
+	}
+}
+
+// Operations is the registry of the operations, the ones running on this replica are kept in memory.
+// With a store they are persisted too, so they are available to every replica and across restarts
+type Operations struct {
+	mu         sync.Mutex
+	operations map[string]*Operation
+	store      Store
+	persisting sync.Mutex
+}
+
+// NewOperations initialize an empty registry of operations, persisted in the store unless it is nil
+func NewOperations(store Store) *Operations {
+	return &Operations{operations: map[string]*Operation{}, store: store}
+}
+
+func (o *Operations) load() (map[string]OperationStatus, string, error) {
+	data, version, err := o.store.Load()
+	if err != nil {
+		return nil, "", err
+	}
+	statuses := map[string]OperationStatus{}
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &statuses)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error while unmarshaling operations")
+		}
+	}
+	return statuses, version, nil
+}
+
+// update applies the change to the stored operations, retrying when they were changed concurrently.
+// The operations finished before the retention period are removed
+func (o *Operations) update(change func(map[string]OperationStatus)) error {
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		statuses, version, err := o.load()
+		if err != nil {
+			return err
+		}
+		change(statuses)
+		for id, status := range statuses {
+			if expired(status) {
+				delete(statuses, id)
+			}
+		}
+		data, err := json.Marshal(statuses)
+		if err != nil {
+			return errors.Wrap(err, "error while converting operations to json")
+		}
+		saved, err := o.store.Save(data, version)
+		if err != nil {
+			return err
+		}
+		if saved {
+			return nil
+		}
+		log.Printf("operations changed concurrently, retrying")
+	}
+	return errors.Errorf("unable to update operations after %d attempts", maxRetries)
+}
+
+// expired check if the operation is no longer available for polling
+func expired(status OperationStatus) bool {
+	if status.FinishedAt != nil {
+		return time.Since(*status.FinishedAt) > operationsRetention
+	}
+	return time.Since(status.CreatedAt) > operationsRetention
+}
+
+// running check if the operation is still running, an abandoned one is not
+func running(status OperationStatus) bool {
+	return status.FinishedAt == nil && time.Since(status.CreatedAt) < abandonedAfter
+}
+
+// Create registers a new pending operation for the namespace of the owner with the steps of the given sequence
+func (o *Operations) Create(namespace, owner string, teams []string, stable bool, seq *Sequence) *Operation {
+	steps := []StepStatus{}
+	for _, name := range seq.Names() {
+		steps = append(steps, StepStatus{Name: name, State: StatePending})
+	}
+	op := &Operation{
+		registry: o,
+		status: OperationStatus{
+			ID:        newOperationID(),
+			Namespace: namespace,
+			Owner:     owner,
+			Teams:     teams,
+			Stable:    stable,
+			State:     StatePending,
+			Steps:     steps,
+			CreatedAt: time.Now(),
+		},
+	}
+	o.mu.Lock()
+	o.prune()
+	o.operations[op.ID()] = op
+	o.mu.Unlock()
+	op.persist()
+	return op
+}
+
+// Get returns the operation with the given id, the ones started by another replica are read from the store
+func (o *Operations) Get(id string) (*Operation, bool) {
+	o.mu.Lock()
+	op, ok := o.operations[id]
+	o.mu.Unlock()
+	if ok || o.store == nil {
+		return op, ok
+	}
+	statuses, _, err := o.load()
+	if err != nil {
+		log.Printf("error while loading operations: %v", err)
+		return nil, false
+	}
+	status, ok := statuses[id]
+	if !ok {
+		return nil, false
+	}
+	return &Operation{status: status}, true
+}
+
+// FromQueue returns the operation started from the queued request with the given id
+func (o *Operations) FromQueue(queueID string) (*Operation, bool) {
+	for _, status := range o.statuses() {
+		if status.QueueID == queueID {
+			return o.Get(status.ID)
+		}
+	}
+	return nil, false
+}
+
+// Pending returns the status of the operations not finished yet, of every replica when they are persisted.
+// The operations abandoned by a replica are not pending
+func (o *Operations) Pending() []OperationStatus {
+	pending := []OperationStatus{}
+	for _, status := range o.statuses() {
+		if running(status) {
+			pending = append(pending, status)
+		}
+	}
+	return pending
+}
+
+// statuses returns the status of the operations of this replica and, when they are persisted, of the other ones
+func (o *Operations) statuses() map[string]OperationStatus {
+	statuses := map[string]OperationStatus{}
+	if o.store != nil {
+		stored, _, err := o.load()
+		if err != nil {
+			log.Printf("error while loading operations: %v", err)
+		}
+		for id, status := range stored {
+			statuses[id] = status
+		}
+	}
+	o.mu.Lock()
+	for id, op := range o.operations {
+		statuses[id] = op.Status()
+	}
+	o.mu.Unlock()
+	return statuses
+}
+
+// prune removes the operations finished before the retention period
+func (o *Operations) prune() {
+	for id, op := range o.operations {
+		status := op.Status()
+		if status.FinishedAt != nil && time.Since(*status.FinishedAt) > operationsRetention {
+			delete(o.operations, id)
+		}
+	}
+}
+
+func newOperationID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,109 @@
+package staging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Step is a single unit of work of the staging creation, with the action that reverts it
+type Step struct {
+	Name string
+	Do   func() error
+	// Undo can be nil when there is nothing to revert or when the revert is covered by a previous step (e.g. namespace deletion)
+	Undo func() error
+}
+
+// StepError describes the step that failed and the errors that occurred while undoing the completed steps
+type StepError struct {
+	Step       string            `json:"failed_step"`
+	Err        error             `json:"-"`
+	UndoErrors map[string]string `json:"undo_errors,omitempty"`
+}
+
+func (e *StepError) Error() string {
+	msg := fmt.Sprintf("step %s failed: %v", e.Step, e.Err)
+	if len(e.UndoErrors) > 0 {
+		undo := []string{}
+		for step, err := range e.UndoErrors {
+			undo = append(undo, fmt.Sprintf("%s: %s", step, err))
+		}
+		msg = fmt.Sprintf("%s (rollback errors: %s)", msg, strings.Join(undo, ", "))
+	}
+	return msg
+}
+
+// Sequence is an ordered list of steps that is rolled back as a whole when one of them fails
+type Sequence struct {
+	steps   []Step
+	tracker Tracker
+}
+
+// NewSequence initialize an empty Sequence
+func NewSequence() *Sequence {
+	return &Sequence{}
+}
+
+// Add appends a step to the sequence
+func (s *Sequence) Add(name string, do, undo func() error) {
+	s.steps = append(s.steps, Step{Name: name, Do: do, Undo: undo})
+}
+
+// Track sets the Tracker notified about the progress of the steps
+func (s *Sequence) Track(t Tracker) {
+	s.tracker = t
+}
+
+// Names returns the names of the steps in order
+func (s *Sequence) Names() []string {
+	names := []string{}
+	for _, step := range s.steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+// Run executes all the steps in order, at the first failure undoes the already completed steps in reverse order
+func (s *Sequence) Run() error {
+	completed := []Step{}
+	for _, step := range s.steps {
+		log.Printf("running step %s", step.Name)
+		if s.tracker != nil {
+			s.tracker.StepStarted(step.Name)
+		}
+		err := step.Do()
+		if s.tracker != nil {
+			s.tracker.StepCompleted(step.Name, err)
+		}
+		if err != nil {
+			log.Printf("step %s failed: %v", step.Name, err)
+			return &StepError{
+				Step:       step.Name,
+				Err:        err,
+				UndoErrors: s.rollback(completed),
+			}
+		}
+		completed = append(completed, step)
+	}
+	return nil
+}
+
+func (s *Sequence) rollback(completed []Step) map[string]string {
+	undoErrors := map[string]string{}
+	for i := len(completed) - 1; i >= 0; i-- {
+		step := completed[i]
+		if step.Undo == nil {
+			continue
+		}
+		log.Printf("undoing step %s", step.Name)
+		err := step.Undo()
+		if s.tracker != nil {
+			s.tracker.StepReverted(step.Name, err)
+		}
+		if err != nil {
+			log.Printf("error while undoing step %s: %v", step.Name, err)
+			undoErrors[step.Name] = err.Error()
+		}
+	}
+	return undoErrors
+}
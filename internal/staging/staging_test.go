@@ -0,0 +1,146 @@
+package staging
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSequenceRun(t *testing.T) {
+	done := []string{}
+	seq := NewSequence()
+	seq.Add("first", func() error {
+		done = append(done, "first")
+		return nil
+	}, nil)
+	seq.Add("second", func() error {
+		done = append(done, "second")
+		return nil
+	}, nil)
+	err := seq.Run()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(done, []string{"first", "second"}) {
+		t.Fatalf("steps executed in wrong order: %v", done)
+	}
+}
+
+func TestSequenceRollback(t *testing.T) {
+	undone := []string{}
+	seq := NewSequence()
+	seq.Add("namespace", func() error { return nil }, func() error {
+		undone = append(undone, "namespace")
+		return nil
+	})
+	seq.Add("ingresses", func() error { return nil }, nil)
+	seq.Add("dns", func() error { return nil }, func() error {
+		undone = append(undone, "dns")
+		return errors.New("record not found")
+	})
+	seq.Add("jenkins", func() error { return errors.New("jenkins unreachable") }, func() error {
+		undone = append(undone, "jenkins")
+		return nil
+	})
+	seq.Add("persistence", func() error {
+		t.Fatal("step after the failing one has been executed")
+		return nil
+	}, nil)
+	err := seq.Run()
+	stepErr, ok := err.(*StepError)
+	if !ok {
+		t.Fatalf("expected a StepError, got %v", err)
+	}
+	if stepErr.Step != "jenkins" {
+		t.Fatalf("expected failed step jenkins, got %s", stepErr.Step)
+	}
+	if !reflect.DeepEqual(undone, []string{"dns", "namespace"}) {
+		t.Fatalf("completed steps not undone in reverse order: %v", undone)
+	}
+	if _, ok := stepErr.UndoErrors["dns"]; !ok || len(stepErr.UndoErrors) != 1 {
+		t.Fatalf("expected undo error only for dns, got %v", stepErr.UndoErrors)
+	}
+}
+
+func TestOperationTracking(t *testing.T) {
+	seq := NewSequence()
+	seq.Add("namespace", func() error { return nil }, func() error { return nil })
+	seq.Add("dns", func() error { return errors.New("throttled") }, nil)
+	seq.Add("jenkins", func() error { return nil }, nil)
+	ops := NewOperations(nil)
+	op := ops.Create("ms-test", "john", nil, false, seq)
+	seq.Track(op)
+	if pending := ops.Pending(); len(pending) != 1 || pending[0].Owner != "john" {
+		t.Fatalf("expected the operation to be pending, got %+v", pending)
+	}
+	op.Finish(nil, seq.Run())
+	if pending := ops.Pending(); len(pending) != 0 {
+		t.Fatalf("expected no pending operation, got %+v", pending)
+	}
+
+	fetched, ok := ops.Get(op.ID())
+	if !ok {
+		t.Fatalf("operation %s not found", op.ID())
+	}
+	status := fetched.Status()
+	if status.State != StateFailed || status.FailedStep != "dns" {
+		t.Fatalf("unexpected operation status: %+v", status)
+	}
+	expected := []string{StateReverted, StateFailed, StatePending}
+	for idx, step := range status.Steps {
+		if step.State != expected[idx] {
+			t.Fatalf("expected step %s to be %s, got %s", step.Name, expected[idx], step.State)
+		}
+	}
+}
+
+// memoryStore is a Store keeping the operations in memory
+type memoryStore struct {
+	data    []byte
+	version int
+}
+
+func (s *memoryStore) Load() ([]byte, string, error) {
+	return s.data, strconv.Itoa(s.version), nil
+}
+
+func (s *memoryStore) Save(data []byte, version string) (bool, error) {
+	if version != strconv.Itoa(s.version) {
+		return false, nil
+	}
+	s.data = data
+	s.version++
+	return true, nil
+}
+
+func TestPersistedOperations(t *testing.T) {
+	store := &memoryStore{}
+	seq := NewSequence()
+	seq.Add("namespace", func() error { return nil }, nil)
+	ops := NewOperations(store)
+	op := ops.Create("ms-test", "john", nil, false, seq)
+	seq.Track(op)
+	op.Dequeued("queued")
+
+	// another replica sharing the store
+	other := NewOperations(store)
+	if pending := other.Pending(); len(pending) != 1 || pending[0].Namespace != "ms-test" {
+		t.Fatalf("expected the operation to be pending on the other replica, got %+v", pending)
+	}
+	op.Finish("done", seq.Run())
+	if dequeued, ok := other.FromQueue("queued"); !ok || dequeued.ID() != op.ID() {
+		t.Fatalf("operation %s not found by queue id on the other replica", op.ID())
+	}
+	fetched, ok := other.Get(op.ID())
+	if !ok {
+		t.Fatalf("operation %s not found on the other replica", op.ID())
+	}
+	status := fetched.Status()
+	if status.State != StateSucceeded || status.Steps[0].State != StateSucceeded {
+		t.Fatalf("unexpected operation status: %+v", status)
+	}
+	if pending := other.Pending(); len(pending) != 0 {
+		t.Fatalf("expected no pending operation, got %+v", pending)
+	}
+}
@@ -0,0 +1,78 @@
+package tracker
+
+import (
+	"log"
+	"time"
+
+	"github.com/lzecca78/one/internal/config"
+	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/spf13/viper"
+)
+
+// leaseName is the name of the lease used to elect the replica following the builds
+const leaseName = "one-tracker"
+
+// Tracker periodically follows the builds started by one in every universe, until they are done
+type Tracker struct {
+	client   *kubernetes.Client
+	elector  *kubernetes.LeaderElector
+	track    func(namespace string) error
+	interval time.Duration
+}
+
+// NewTracker initialize the Tracker, the track function follows the builds of a single universe
+func NewTracker(v *viper.Viper, client *kubernetes.Client, track func(namespace string) error) *Tracker {
+	interval, err := time.ParseDuration(config.CheckAndGetString(v, "TRACKER_INTERVAL"))
+	if err != nil {
+		log.Fatal("failed converting TRACKER_INTERVAL to duration:", err)
+	}
+	tracker := &Tracker{
+		client:   client,
+		track:    track,
+		interval: interval,
+	}
+	if config.CheckAndGetBool(v, "LEADER_ELECTION") {
+		// the lease outlives a missed renewal, so the leader does not flap between replicas
+		tracker.elector = client.NewLeaderElector(leaseName, 2*interval)
+	}
+	return tracker
+}
+
+// activeNamespaces returns the namespaces not being deleted
+func activeNamespaces(namespaces []kubernetes.MyNameSpace) []string {
+	active := []string{}
+	for _, namespace := range namespaces {
+		if namespace.Status == "Active" {
+			active = append(active, namespace.Name)
+		}
+	}
+	return active
+}
+
+// Run follows the builds every interval, it never returns
+func (t *Tracker) Run() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		t.Track()
+		<-ticker.C
+	}
+}
+
+// Track follows the builds of every universe once, only the leader acts when leader election is enabled
+func (t *Tracker) Track() {
+	if t.elector != nil && !t.elector.IsLeader() {
+		return
+	}
+	namespaces, err := t.client.NamespaceManagedList()
+	if err != nil {
+		log.Printf("tracker unable to list namespaces: %v", err)
+		return
+	}
+	for _, namespace := range activeNamespaces(namespaces) {
+		err := t.track(namespace)
+		if err != nil {
+			log.Printf("tracker unable to follow the builds of namespace %s: %v", namespace, err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package tracker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lzecca78/one/internal/kubernetes"
+)
+
+func TestActiveNamespaces(t *testing.T) {
+	namespaces := []kubernetes.MyNameSpace{
+		{Name: "ms-active", Status: "Active"},
+		{Name: "ms-sleeping", Status: "Active", Sleeping: true},
+		{Name: "ms-terminating", Status: "Terminating"},
+	}
+	got := activeNamespaces(namespaces)
+	want := []string{"ms-active", "ms-sleeping"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -23,6 +23,12 @@ func (l *Locks) LoadOrStoreLock(namespace string) {
 	log.Printf("locked for %v", namespace)
 }
 
+//TryLock locks the namespace only if it is not locked already, it returns true if the lock is taken
+func (l *Locks) TryLock(namespace string) bool {
+	lock, _ := l.namespaces.LoadOrStore(namespace, &sync.Mutex{})
+	return lock.(*sync.Mutex).TryLock()
+}
+
 //Unlock takes the namespace and further release the lock
 func (l *Locks) Unlock(namespace string) {
 	log.Printf("unlocking for %v", namespace)
@@ -15,6 +15,9 @@ type MultistagingSpecs struct {
 	Status    string     `json:"status"`
 	JobName   string     `json:"job_name"`
 	CVSRefs   git.Commit `json:"cvs_refs"`
+	// DeployedSha is the sha of the last completed build started by one, ShaMismatch flags it when it is not the one of CVSRefs
+	DeployedSha string `json:"deployed_sha,omitempty"`
+	ShaMismatch bool   `json:"sha_mismatch,omitempty"`
 }
 
 // RemoveDuplicatesFromSlice remove duplicate item from a slice
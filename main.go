@@ -1,19 +1,26 @@
 package main
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/lzecca78/one/internal/auth"
+	"github.com/lzecca78/one/internal/ci"
 	"github.com/lzecca78/one/internal/config"
 	"github.com/lzecca78/one/internal/git"
-	"github.com/lzecca78/one/internal/jenkins"
 	"github.com/lzecca78/one/internal/kubernetes"
+	"github.com/lzecca78/one/internal/naming"
+	"github.com/lzecca78/one/internal/pipeline"
+	"github.com/lzecca78/one/internal/queue"
+	"github.com/lzecca78/one/internal/quota"
+	"github.com/lzecca78/one/internal/reaper"
+	"github.com/lzecca78/one/internal/reconciler"
 	"github.com/lzecca78/one/internal/route53"
 	"github.com/lzecca78/one/internal/routes"
+	"github.com/lzecca78/one/internal/sleeper"
+	"github.com/lzecca78/one/internal/staging"
+	"github.com/lzecca78/one/internal/tracker"
 	"github.com/lzecca78/one/internal/utils"
 
 	"github.com/gin-contrib/cors"
@@ -27,19 +34,43 @@ func main() {
 	log.SetFlags(log.Lshortfile)
 	v := config.GetConfig()
 	client := git.NewGitClient(v)
-	jenkinsClient := jenkins.NewJenkinsClient(v)
-	kubernetesClient := kubernetes.NewKubernetesClient(v)
+	ciProvider, err := ci.ProviderSet(v)
+	if err != nil {
+		log.Fatalf("error while setting the ci provider %v", err)
+	}
+	namer := naming.NewNamer(v)
+	kubernetesClient := kubernetes.NewKubernetesClient(v, namer.Validate)
 	r53cli := route53.NewRoute53Client(v)
 
 	allClients := routes.Clients{
 		ViperEnvConfig:   v,
 		GitClient:        client,
-		JenkinsClient:    jenkinsClient,
+		CIProvider:       ciProvider,
 		KubernetesClient: kubernetesClient,
 		R53client:        r53cli,
 	}
+	err = kubernetesClient.MigrateConfigMaps()
+	if err != nil {
+		log.Printf("error while migrating legacy configmaps: %v", err)
+	}
 	globalLocks = utils.NewLocks()
-	router := routes.NewRouter(&allClients, globalLocks)
+	driftReconciler := reconciler.NewReconciler(v, kubernetesClient, r53cli, ciProvider, globalLocks, namer.Validate)
+	operations := staging.NewOperations(kubernetesClient.NewStateStore("operations"))
+	router := routes.NewRouter(&allClients, globalLocks, operations, namer, quota.GetQuotas(v), queue.NewQueue(kubernetesClient.NewStateStore("queue")), driftReconciler)
+	//delete the expired universes in background
+	go reaper.NewReaper(v, kubernetesClient, router.TeardownNamespace).Run()
+	//start the queued requests as soon as a slot frees up
+	go queue.NewProcessor(v, kubernetesClient, router.Queue, router.StartQueued(func(jobsParams *pipeline.JobsParameters) (bool, error) {
+		return PreconditionCheck(kubernetesClient, jobsParams, router.PendingUniverses())
+	})).Run()
+	//follow the builds started by one until they are done
+	go tracker.NewTracker(v, kubernetesClient, router.TrackBuilds).Run()
+	//report and repair the drift between kubernetes, route53 and jenkins
+	go driftReconciler.Run()
+	//put the universes to sleep outside of working hours
+	if scheduler := sleeper.NewSleeper(v, kubernetesClient, router.SleepUniverse, router.WakeUniverse); scheduler != nil {
+		go scheduler.Run()
+	}
 	r := setup(router)
 	r.Run(":8080") // listen and serve on 0.0.0.0:8080
 }
@@ -55,83 +86,158 @@ func setup(router *routes.Router) *gin.Engine {
 		log.Printf("received: %+v", *c.Request)
 	})
 	api := r.Group("/api")
-	auth, err := auth.AdapterSet(router.ViperEnvConfig, api, "/private")
+	private, err := auth.AdapterSet(router.ViperEnvConfig, api, "/private")
 	if err != nil {
 		log.Fatalf("error while setting the auth adapter %v", err)
 	}
-	auth.GET("/config", func(c *gin.Context) {
-		c.JSON(http.StatusOK, router.JenkinsClient.Config)
+	private.GET("/config", func(c *gin.Context) {
+		c.JSON(http.StatusOK, router.CIProvider.Config())
 	})
-	auth.GET("/repos", func(c *gin.Context) {
-		repos, err := router.GitClient.GetRepos(router.JenkinsClient.GetRepos())
+	private.GET("/repos", func(c *gin.Context) {
+		repos, err := router.GitClient.GetRepos(router.CIProvider.Repos())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, repos)
 	})
-	auth.POST("/stagings", func(c *gin.Context) {
-		var jobsParams jenkins.JobsParameters
+	private.POST("/stagings", func(c *gin.Context) {
+		var jobsParams pipeline.JobsParameters
 		err := c.BindJSON(&jobsParams)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		//create unique namespace name
-		namespace := kubernetes.NsNameGen(jobsParams)
-		//get lock for for chosen namespace
-		globalLocks.LoadOrStoreLock(namespace)
-		defer globalLocks.Unlock(namespace)
+		jobsParams.Profile, err = router.KubernetesClient.ResolveProfile(jobsParams.Profile)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		//the admission lock is taken before the lock of the namespace, as for the queued requests,
+		//so the creations started by the concurrent requests are counted by the following checks
+		router.LockAdmission()
+		defer router.UnlockAdmission()
+		//create unique namespace name, its lock is released by the background creation once started
+		namespace, err := router.UniverseName(c, jobsParams)
+		if err == routes.ErrNameLocked {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		//if the namespace already exists, i give as a response a redirect to the already existing namespace
-		if router.KubernetesClient.NamespaceAlreadyCreated(namespace) {
+		if router.NamespaceTaken(namespace) {
+			globalLocks.Unlock(namespace)
 			//redirectUrl := fmt.Sprintf("%s/api/stagings/%s", c.Request.Header.Get("HOST"), namespace)
 			c.JSON(http.StatusConflict, "resource already exists")
 			return
 		}
+		//check the quotas of the user and of its teams
+		violation, err := router.CheckQuotas(c, jobsParams.Stable)
+		if err != nil {
+			globalLocks.Unlock(namespace)
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
+		if violation != nil {
+			globalLocks.Unlock(namespace)
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": violation.Error(), "quota": violation})
+			return
+		}
 		//check if a new namespace can be created or not
-		check, err := PreconditionCheck(router.KubernetesClient, &jobsParams)
+		check, err := PreconditionCheck(router.KubernetesClient, &jobsParams, router.PendingUniverses())
+		if (err != nil || !check) && jobsParams.Queue {
+			globalLocks.Unlock(namespace)
+			router.EnqueueStaging(c, jobsParams, namespace)
+			return
+		}
 		if err != nil {
+			globalLocks.Unlock(namespace)
 			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
 			return
 		}
 		if !check {
+			globalLocks.Unlock(namespace)
 			message := "maximum number of universes already reached"
 			c.JSON(http.StatusPreconditionFailed, message)
 			return
 		}
-		CreateStagingEntity(jobsParams, namespace, router, c)
+		op := router.StartStagingCreation(jobsParams, namespace, auth.CurrentUser(c), auth.CurrentTeams(c))
+		c.Header("Location", fmt.Sprintf("/api/operations/%s", op.ID()))
+		c.JSON(http.StatusAccepted, gin.H{"operation_id": op.ID(), "namespace": namespace})
 	})
-	auth.DELETE("/stagings/:namespace", router.DeleteNamespace())
+	private.GET("/operations/:id", router.GetOperation())
+	private.GET("/queue", router.ListQueue())
+	private.GET("/queue/:id", router.GetQueued())
+	private.PUT("/queue/:id", router.MoveQueued())
+	private.DELETE("/queue/:id", router.CancelQueued())
+	private.DELETE("/stagings/:namespace", router.DeleteNamespace())
 	api.DELETE("/stagings/:namespace", router.CheckNamespaceSecret(router.DeleteNamespace()))
-	auth.GET("/stagings", func(c *gin.Context) {
+	private.PATCH("/stagings/:namespace", router.UpdateNamespace())
+	private.POST("/stagings/:namespace/extend", router.ExtendNamespace())
+	private.POST("/stagings/:namespace/promote", router.PromoteNamespace())
+	private.POST("/stagings/:namespace/demote", router.DemoteNamespace())
+	private.POST("/stagings/:namespace/sleep", router.SleepNamespace())
+	private.POST("/stagings/:namespace/wake", router.WakeNamespace())
+	private.GET("/admin/drift", router.RequireAdmin(router.GetDrift()))
+	private.POST("/admin/drift/repair", router.RequireAdmin(router.RepairDrift()))
+	private.GET("/stagings", func(c *gin.Context) {
 		listNs, err := router.KubernetesClient.NamespaceManagedList()
 		log.Printf("listNs is: %v", listNs)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, listNs)
+		filter := kubernetes.NamespaceFilter{
+			Owner:  c.Query("owner"),
+			Repo:   c.Query("repo"),
+			Branch: c.Query("branch"),
+		}
+		filtered := []kubernetes.MyNameSpace{}
+		for _, namespace := range listNs {
+			if filter.Matches(namespace) {
+				filtered = append(filtered, namespace)
+			}
+		}
+		c.JSON(http.StatusOK, filtered)
 	})
-	auth.GET("/stagings/:namespace", func(c *gin.Context) {
+	private.GET("/stagings/:namespace", func(c *gin.Context) {
 		namespace := c.Param("namespace")
 		//get lock for for chosen namespace
 		globalLocks.LoadOrStoreLock(namespace)
 		defer globalLocks.Unlock(namespace)
-		var data *kubernetes.CloneIngressResponse
-		data, _, err := router.KubernetesClient.GetConfigMap(namespace, namespace)
+		universe, err := router.KubernetesClient.GetUniverse(namespace)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		//resp, err := json.Marshal(data)
-		c.JSON(http.StatusOK, data)
+		quota, err := router.KubernetesClient.GetQuotaUsage(namespace)
+		if err != nil {
+			log.Printf("error while getting quota usage of namespace %s: %v", namespace, err)
+		}
+		c.JSON(http.StatusOK, kubernetes.UniverseView{Universe: universe, Quota: quota})
 	})
-	auth.GET("/stagings/:namespace/pipelines/status", func(c *gin.Context) {
+	private.GET("/stagings/:namespace/health", router.NamespaceHealth())
+	private.GET("/stagings/:namespace/logs/:project", router.StreamLogs())
+	private.GET("/stagings/:namespace/pipelines", router.ListPipelines())
+	//the router does not allow a static segment next to :repo, so status is matched as a repo
+	private.GET("/stagings/:namespace/pipelines/:repo", func(c *gin.Context) {
+		if c.Param("repo") != "status" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
 		namespace := c.Param("namespace")
 		globalLocks.LoadOrStoreLock(namespace)
 		defer globalLocks.Unlock(namespace)
-		var data jenkins.JobsStatuses
-		data, err := router.JenkinsClient.GetJobStatus(namespace)
+		universe, err := router.KubernetesClient.GetUniverse(namespace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var data pipeline.JobsStatuses
+		data, err = router.PipelineStatuses(universe)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -139,34 +245,50 @@ func setup(router *routes.Router) *gin.Engine {
 		c.JSON(http.StatusOK, data)
 
 	})
-	auth.POST("/stagings/:namespace/pipelines/:repo", func(c *gin.Context) {
+	private.GET("/stagings/:namespace/pipelines/:repo/log", router.StreamPipelineLog())
+	private.POST("/stagings/:namespace/pipelines/:repo", func(c *gin.Context) {
 		namespace := c.Param("namespace")
 		repo := c.Param("repo")
 		log.Printf("repo is %s", repo)
 		globalLocks.LoadOrStoreLock(namespace)
 		defer globalLocks.Unlock(namespace)
-		data, _, err := router.KubernetesClient.GetConfigMap(namespace, namespace)
-		log.Printf("configMap is %+v", data)
-		branch := data.ProjectsWithDetails[repo].CVSRefs.Branch
-		log.Printf("branch is %s", branch)
-		err = router.JenkinsClient.ReplayJob(repo, repo, branch, namespace)
+		universe, err := router.KubernetesClient.GetUniverse(namespace)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		commit, ok := universe.Spec.CommitPerProject[repo]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("project %s not found in namespace %s", repo, namespace)})
+			return
+		}
+		log.Printf("branch is %s", commit.Branch)
+		trigger, err := router.CIProvider.Replay(repo, namespace, universe.JobsParameters().BuildContext(repo))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if trigger != nil {
+			universe.SetTrigger(repo, trigger)
+			err = router.KubernetesClient.UpdateUniverse(universe)
+			if err != nil {
+				log.Printf("error while recording trigger of %s in namespace %s: %v", repo, namespace, err)
+			}
+		}
 		c.JSON(http.StatusCreated, fmt.Sprintf("re-playing the pipeline in namespace %s for job %s", namespace, repo))
 	})
 	return r
 }
 
-// PreconditionCheck will check if the total number multistaging stable and not are under the params passed from env var
-func PreconditionCheck(client *kubernetes.Client, jobsParams *jenkins.JobsParameters) (res bool, err error) {
-	res, err = client.UnderMaxNsLimit()
+// PreconditionCheck will check if the total number multistaging stable and not are under the params passed from env var,
+// the pending universes are the ones still being created
+func PreconditionCheck(client *kubernetes.Client, jobsParams *pipeline.JobsParameters, pending []kubernetes.MyNameSpace) (res bool, err error) {
+	res, err = client.UnderMaxNsLimit(pending...)
 	if err != nil {
 		return false, err
 	}
 	if jobsParams.Stable {
-		maxStableLimit, err := client.UnderMaxStableNsLimit()
+		maxStableLimit, err := client.UnderMaxStableNsLimit(pending...)
 		res = res && maxStableLimit
 		if err != nil {
 			return false, err
@@ -174,66 +296,3 @@ func PreconditionCheck(client *kubernetes.Client, jobsParams *jenkins.JobsParame
 	}
 	return res, nil
 }
-
-// CreateStagingEntity is the logic called from the POST api /stagings
-func CreateStagingEntity(jobsParams jenkins.JobsParameters, namespace string, router *routes.Router, c *gin.Context) {
-	err := router.KubernetesClient.CreateNamespace(namespace, jobsParams.Stable)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	keys := make([]string, 0, len(router.JenkinsClient.Config.RepositoriesProperties.Conf))
-	for k := range router.JenkinsClient.Config.RepositoriesProperties.Conf {
-		keys = append(keys, k)
-	}
-	//clone ingress from source namespace (staging) with custom new values
-	kresp, err := router.KubernetesClient.CloneIngresses(namespace, keys)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	var deleteSecret [32]byte
-	_, err = rand.Read(deleteSecret[:])
-	if err != nil {
-		log.Printf("error reading random secret: %s", err)
-	}
-	// adding creation of random string for cli authentication on delete api
-	deleteSecretString := base64.StdEncoding.EncodeToString(deleteSecret[:])
-	//create cronjob that will destroy everything calling the route with DELETE
-	err = router.KubernetesClient.CreateCronjob(namespace, jobsParams.Stable, deleteSecretString)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	for _, ingressList := range kresp.ProjectsWithDetails {
-		for _, ingressHost := range ingressList.Ingresses {
-			//create record for each ingress created previously
-			resp, err := router.R53client.CreateRecordSet(ingressHost)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				return
-			}
-			log.Println(resp)
-		}
-	}
-	//initialization of all pipelines of all projects describe in the main config file with custom parameters(branch, namespace and commit)
-	projectJobMap, err := router.JenkinsClient.ConfigureJobs(&jobsParams, namespace)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	// enrich with jenkins job status
-	js, err := router.JenkinsClient.GetJobStatus(namespace)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	newCloneIngResp := kubernetes.EnrichCloneIngressResp(js, kresp, &jobsParams)
-	//create cm as persistence layer with CloneIngressResponse struct inside
-	err = router.KubernetesClient.CreateConfigMap(newCloneIngResp, projectJobMap, deleteSecretString)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(http.StatusCreated, kresp)
-}